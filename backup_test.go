@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	mustInsert(t, kv, "a", "3")
+	if err := kv.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "c", "4")
+
+	var buf bytes.Buffer
+	if err := kv.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if int64(buf.Len()) != fileSize(t, kv) {
+		t.Fatalf("backup is %d bytes, file is %d", buf.Len(), fileSize(t, kv))
+	}
+
+	path := filepath.Join(dir, "restored.db")
+	if err := Restore(path, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	wantSameContents(t, kv, openTestKVAt(t, path, Options{}))
+}
+
+func TestRestoreReplacesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	old := openTestKVAt(t, path, Options{Perm: 0o600})
+	mustInsert(t, old, "old", "1")
+	old.Close()
+	if _, err := os.Stat(indexPath(path)); err != nil {
+		t.Fatalf("no sidecar index to replace: %v", err)
+	}
+
+	if err := Restore(path, strings.NewReader("not a backup")); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("restore of garbage: %v", err)
+	}
+	kv := openTestKVAt(t, path, Options{})
+	wantValue(t, kv, "old", "1")
+	kv.Close()
+
+	src := openTestKV(t)
+	mustInsert(t, src, "new", "2")
+	var buf bytes.Buffer
+	if err := src.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(path, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(indexPath(path)); err == nil {
+		t.Fatal("sidecar index of the replaced file was kept")
+	}
+	wantPerm(t, path, 0o600)
+	kv = openTestKVAt(t, path, Options{})
+	wantSameContents(t, src, kv)
+}
@@ -0,0 +1,45 @@
+package main
+
+import "sort"
+
+// Batch collects writes to apply to a database together. Nothing is written
+// until Commit.
+type Batch struct {
+	kv      *KV
+	set     map[string][]byte
+	removed map[string]bool
+}
+
+// NewBatch returns an empty batch for kv.
+func (kv *KV) NewBatch() *Batch {
+	return &Batch{kv: kv, set: make(map[string][]byte), removed: make(map[string]bool)}
+}
+
+// Put adds a write of value under key to the batch, replacing any earlier Put
+// or Delete of key in it.
+func (b *Batch) Put(key string, value []byte) {
+	delete(b.removed, key)
+	b.set[key] = append([]byte(nil), value...)
+}
+
+// Delete adds the removal of key to the batch, replacing any earlier Put of
+// key in it. Keys that are missing when the batch is committed are skipped.
+func (b *Batch) Delete(key string) {
+	delete(b.set, key)
+	b.removed[key] = true
+}
+
+// Commit writes every operation in the batch with a single write, as
+// ApplyPatch does, so either all of them reach the index or none do. The
+// batch is empty again afterwards, whether or not Commit succeeded.
+func (b *Batch) Commit() error {
+	patch := Patch{Set: b.set}
+	for key := range b.removed {
+		patch.Removed = append(patch.Removed, key)
+	}
+	sort.Strings(patch.Removed)
+
+	b.set = make(map[string][]byte)
+	b.removed = make(map[string]bool)
+	return b.kv.ApplyPatch(patch)
+}
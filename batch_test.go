@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "old", "v")
+	mustInsert(t, kv, "replaced", "before")
+
+	batch := kv.NewBatch()
+	for i := 0; i < 50; i++ {
+		batch.Put(fmt.Sprintf("key-%02d", i), []byte(fmt.Sprint(i)))
+	}
+	batch.Put("replaced", []byte("after"))
+	batch.Delete("old")
+	batch.Put("put-then-deleted", []byte("v"))
+	batch.Delete("put-then-deleted")
+	batch.Delete("deleted-then-put")
+	batch.Put("deleted-then-put", []byte("v"))
+	batch.Delete("missing")
+
+	// Nothing is written before Commit.
+	end := kv.lastOffset
+	if kv.Exists("key-00") || kv.lastOffset != end {
+		t.Fatal("uncommitted batch changed the database")
+	}
+
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if kv.Len() != 52 {
+		t.Fatalf("Len after Commit = %d, want 52", kv.Len())
+	}
+	wantValue(t, kv, "key-49", "49")
+	wantValue(t, kv, "replaced", "after")
+	wantValue(t, kv, "deleted-then-put", "v")
+	if kv.Has("old") || kv.Has("put-then-deleted") {
+		t.Fatal("batch deletes were not applied")
+	}
+
+	// The batch is empty after Commit.
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if kv.Len() != 52 {
+		t.Fatalf("Len after Reopen = %d, want 52", kv.Len())
+	}
+}
+
+func TestBatchAllOrNothing(t *testing.T) {
+	kv := openTestKV(t, WithMaxKeySize(8))
+	mustInsert(t, kv, "a", "1")
+	end := kv.lastOffset
+
+	batch := kv.NewBatch()
+	batch.Put("b", []byte("2"))
+	batch.Delete("a")
+	batch.Put("key too long", []byte("3"))
+	if err := batch.Commit(); !errors.Is(err, ErrKeyTooLarge) {
+		t.Fatalf("Commit with an oversized key: %v", err)
+	}
+	if kv.lastOffset != end || kv.Has("b") {
+		t.Fatal("failed batch was partly applied")
+	}
+	wantValue(t, kv, "a", "1")
+}
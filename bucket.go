@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// bucketMeta is the metadata entry naming the bucket a record belongs to.
+// Records without it are in the default key space that the methods of KV
+// work on. Tombstones of bucket keys carry it as well, so loading the file
+// routes every record to the index of its own bucket.
+const bucketMeta = reservedMetaPrefix + "bucket"
+
+// Bucket is a named key space within a database. The same key holds
+// independent values in different buckets and in the default key space used
+// by the methods of KV. Every record written through a Bucket is tagged with
+// the bucket's name, so buckets are kept by Compact, the sidecar index,
+// CompactToWriter, ExportConsistent and reloading. Key listings, scans,
+// comparisons, quotas, pins, write callbacks and the other per-key features
+// of KV cover the default key space only.
+//
+// Versions of voila without buckets read the tag as ordinary metadata and
+// would see bucket keys in the default key space, so a file with buckets
+// should not be opened by them.
+type Bucket struct {
+	kv   *KV
+	name string
+	// meta is the metadata block tagging a record with the bucket's name,
+	// or nil for the default key space.
+	meta []byte
+}
+
+// Bucket returns the bucket called name. Buckets need no creating; a bucket
+// with no keys simply has nothing stored in it. Bucket("") is the default key
+// space.
+func (kv *KV) Bucket(name string) *Bucket {
+	b := &Bucket{kv: kv, name: name}
+	if name != "" {
+		b.meta = encodeMeta(map[string]string{bucketMeta: name})
+	}
+	return b
+}
+
+// Buckets returns the names of the buckets that hold keys, in sorted order.
+func (kv *KV) Buckets() []string {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	names := make([]string, 0, len(kv.buckets))
+	for name, pages := range kv.buckets {
+		if len(pages) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Insert stores value under key in the bucket.
+func (b *Bucket) Insert(key string, value []byte) error {
+	return b.kv.insertThrottled(context.Background(), key, b.meta, value)
+}
+
+// Get returns the value stored under key in the bucket.
+func (b *Bucket) Get(key string) ([]byte, error) {
+	kv := b.kv
+	if b.name == "" {
+		return kv.Get(key)
+	}
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return nil, ErrDBNotOpen
+	}
+	page, ok := kv.buckets[b.name][key]
+	kv.metrics.countGet(ok)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	return kv.readValue(key, page)
+}
+
+// Delete removes key from the bucket by appending a tombstone tagged with the
+// bucket's name.
+func (b *Bucket) Delete(key string) error {
+	kv := b.kv
+	if err := kv.throttle(context.Background(), recordSize(key, b.meta, nil)); err != nil {
+		return err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.removeIn(b.name, key)
+}
+
+// Keys returns the keys in the bucket in ascending order.
+func (b *Bucket) Keys() []string {
+	kv := b.kv
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.sortedKeysOf(kv.bucketPages(b.name, false))
+}
+
+// bucketPages returns the index of the named bucket, which for "" is the
+// default key space. A missing bucket index is created if create is set and
+// is nil otherwise.
+func (kv *KV) bucketPages(bucket string, create bool) map[string]Page {
+	if bucket == "" {
+		return kv.pages
+	}
+	pages := kv.buckets[bucket]
+	if pages == nil && create {
+		if kv.buckets == nil {
+			kv.buckets = make(map[string]map[string]Page)
+		}
+		pages = make(map[string]Page)
+		kv.buckets[bucket] = pages
+	}
+	return pages
+}
+
+// eachBucketPage calls fn with every live record of the named buckets, by
+// bucket name and then by key in byte order, stopping at the first error.
+func (kv *KV) eachBucketPage(fn func(bucket, key string, page Page) error) error {
+	names := make([]string, 0, len(kv.buckets))
+	for name := range kv.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pages := kv.buckets[name]
+		keys := make([]string, 0, len(pages))
+		for key, page := range pages {
+			if !page.expired() {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := fn(name, key, pages[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bucketRecords returns the records of eachBucketPage in order, for copying
+// into a compacted file.
+func (kv *KV) bucketRecords() []Page {
+	var records []Page
+	kv.eachBucketPage(func(_, _ string, page Page) error {
+		records = append(records, page)
+		return nil
+	})
+	return records
+}
+
+// metaBucket returns the bucket named in a metadata block, including its
+// length prefix, or "" for the default key space.
+func metaBucket(block []byte) string {
+	if len(block) < 8 {
+		return ""
+	}
+	meta, err := decodeMeta(block[8:])
+	if err != nil {
+		return ""
+	}
+	return meta[bucketMeta]
+}
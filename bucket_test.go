@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fillBuckets writes the same keys to the default key space and to two
+// buckets, then deletes one bucket key, and returns what each bucket should
+// hold afterwards.
+func fillBuckets(t *testing.T, kv *KV) map[string]map[string]string {
+	t.Helper()
+	mustInsert(t, kv, "k", "default")
+	mustInsert(t, kv, "only", "default")
+	for _, name := range []string{"a", "b"} {
+		b := kv.Bucket(name)
+		for _, key := range []string{"k", "gone"} {
+			if err := b.Insert(key, []byte(name+"-"+key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := kv.Bucket("a").Insert("k", []byte("a-k2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Bucket("b").Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]map[string]string{
+		"":  {"k": "default", "only": "default"},
+		"a": {"gone": "a-gone", "k": "a-k2"},
+		"b": {"k": "b-k"},
+	}
+}
+
+// wantBuckets fails the test unless every bucket of kv holds exactly the keys
+// and values in want.
+func wantBuckets(t *testing.T, kv *KV, want map[string]map[string]string) {
+	t.Helper()
+	var names []string
+	for name := range want {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	if got := kv.Buckets(); len(got) != len(names) {
+		t.Fatalf("Buckets = %q, want %d buckets", got, len(names))
+	}
+	for name, contents := range want {
+		b := kv.Bucket(name)
+		var keys []string
+		for key, value := range contents {
+			keys = append(keys, key)
+			got, err := b.Get(key)
+			if err != nil || string(got) != value {
+				t.Fatalf("bucket %q Get(%q) = %q, %v; want %q", name, key, got, err, value)
+			}
+		}
+		if got := b.Keys(); len(got) != len(keys) {
+			t.Fatalf("bucket %q Keys = %q, want %d keys", name, got, len(keys))
+		}
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	kv := openTestKV(t)
+	want := fillBuckets(t, kv)
+	wantBuckets(t, kv, want)
+
+	if got := kv.Bucket("a").Keys(); !reflect.DeepEqual(got, []string{"gone", "k"}) {
+		t.Fatalf("Keys = %q", got)
+	}
+	if got := kv.Buckets(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("Buckets = %q", got)
+	}
+	// Bucket keys stay out of the default key space.
+	if got, _ := kv.KeysPage("", 10); !reflect.DeepEqual(got, []string{"k", "only"}) {
+		t.Fatalf("Keys = %q", got)
+	}
+	if kv.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", kv.Len())
+	}
+	if _, err := kv.Bucket("b").Get("gone"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get deleted key: %v", err)
+	}
+	if err := kv.Bucket("b").Delete("only"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Delete of a key in another bucket: %v", err)
+	}
+	if _, err := kv.Bucket("none").Get("k"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get from empty bucket: %v", err)
+	}
+	if got := kv.Stats().Keys; got != 5 {
+		t.Fatalf("Stats.Keys = %d, want 5", got)
+	}
+	if err := kv.ValidateIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bucket tag is reserved metadata.
+	err := kv.InsertWithMeta("x", []byte("v"), map[string]string{bucketMeta: "a"})
+	if !errors.Is(err, ErrReservedMeta) {
+		t.Fatalf("InsertWithMeta with bucket tag: %v", err)
+	}
+}
+
+func TestBucketsSurviveReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := NewKV()
+	if err := kv.ConnectWithOptions(path, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	want := fillBuckets(t, kv)
+	dead := kv.DeadBytes()
+	if err := kv.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// From the sidecar index, then by scanning the file.
+	for _, sidecar := range []bool{true, false} {
+		if !sidecar {
+			if err := os.Remove(indexPath(path)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		kv = openTestKVAt(t, path, Options{ReadOnly: true})
+		wantBuckets(t, kv, want)
+		if kv.DeadBytes() != dead {
+			t.Fatalf("sidecar %v: DeadBytes = %d, want %d", sidecar, kv.DeadBytes(), dead)
+		}
+		if err := kv.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kv = openTestKVAt(t, path, Options{})
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	wantBuckets(t, kv, want)
+	if err := kv.ValidateIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	wantBuckets(t, kv, want)
+	if kv.DeadBytes() != 0 {
+		t.Fatalf("DeadBytes after Compact = %d", kv.DeadBytes())
+	}
+}
+
+func TestBucketsExported(t *testing.T) {
+	kv := openTestKV(t)
+	want := fillBuckets(t, kv)
+
+	exports := map[string]func(w *bytes.Buffer) error{
+		"CompactToWriter":  func(w *bytes.Buffer) error { return kv.CompactToWriter(w, false) },
+		"ExportConsistent": func(w *bytes.Buffer) error { return kv.ExportConsistent(w) },
+	}
+	for name, export := range exports {
+		var out bytes.Buffer
+		if err := export(&out); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		path := filepath.Join(t.TempDir(), "restored.db")
+		if err := Restore(path, &out); err != nil {
+			t.Fatalf("%s: Restore: %v", name, err)
+		}
+		restored := openTestKVAt(t, path, Options{ReadOnly: true})
+		wantBuckets(t, restored, want)
+		if err := restored.Verify(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestBucketsReplay(t *testing.T) {
+	a := openTestKV(t)
+	var log bytes.Buffer
+	a.RecordOps(&log)
+	want := fillBuckets(t, a)
+
+	b := openTestKV(t)
+	if err := b.Replay(bytes.NewReader(log.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	wantBuckets(t, b, want)
+}
+
+func TestDuplicatesIgnoresBuckets(t *testing.T) {
+	kv := openTestKV(t)
+	fillBuckets(t, kv)
+	dups, err := kv.Duplicates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dups) != 0 {
+		t.Fatalf("Duplicates = %v, want none", dups)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteBuffer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{}, WithWriteBuffer(1024))
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	if size := fileSize(t, kv); size != int64(fileHeaderSize) {
+		t.Fatalf("file is %d bytes before Flush", size)
+	}
+
+	// Reads see buffered records.
+	wantValue(t, kv, "a", "1")
+	if size := fileSize(t, kv); uint64(size) != kv.lastOffset {
+		t.Fatalf("file is %d bytes after a read, want %d", size, kv.lastOffset)
+	}
+
+	mustInsert(t, kv, "c", "3")
+	if err := kv.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if size := fileSize(t, kv); uint64(size) != kv.lastOffset {
+		t.Fatalf("file is %d bytes after Flush, want %d", size, kv.lastOffset)
+	}
+
+	// Filling the buffer writes it out, and a record larger than the
+	// buffer is written straight through.
+	for i := 0; i < 100; i++ {
+		mustInsert(t, kv, fmt.Sprintf("key-%d", i), "value")
+	}
+	mustInsert(t, kv, "big", strings.Repeat("x", 2048))
+	if size := fileSize(t, kv); uint64(size) != kv.lastOffset {
+		t.Fatalf("file is %d bytes after a large record, want %d", size, kv.lastOffset)
+	}
+
+	mustInsert(t, kv, "last", "v")
+	if err := kv.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	kv = openTestKVAt(t, path, Options{})
+	wantValue(t, kv, "last", "v")
+	wantValue(t, kv, "key-99", "value")
+	if kv.Has("a") {
+		t.Fatal("buffered delete was lost")
+	}
+	if err := kv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkInsert100k times 100,000 sequential inserts of small values with
+// and without a write buffer.
+func BenchmarkInsert100k(b *testing.B) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%06d", i)
+	}
+	value := []byte("small value")
+
+	for name, opts := range map[string][]Option{
+		"unbuffered": nil,
+		"buffered":   {WithWriteBuffer(64 << 10)},
+	} {
+		b.Run(name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				kv := openTestKV(b, opts...)
+				b.StartTimer()
+				for _, key := range keys {
+					if err := kv.Insert(key, value); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := kv.Flush(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
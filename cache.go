@@ -0,0 +1,135 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithValueCache keeps recently read values in memory so repeated reads of
+// hot keys skip the file. The cache holds at most maxEntries values and at
+// most maxBytes bytes of them; 0 leaves that limit off, and with both 0 there
+// is no cache. The least recently used values are evicted first. Writing or
+// deleting a key drops its cached value.
+func WithValueCache(maxEntries, maxBytes int) Option {
+	return func(kv *KV) {
+		kv.cache = newValueCache(maxEntries, maxBytes)
+	}
+}
+
+// valueCache is an LRU cache of decoded values. It has its own lock because
+// values are read, and so cached, under the read lock of the KV. All methods
+// are safe to call on a nil cache.
+type valueCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	order      *list.List
+	entries    map[string]*list.Element
+	hits       uint64
+	misses     uint64
+}
+
+type cacheEntry struct {
+	key    string
+	offset uint64
+	value  []byte
+}
+
+func newValueCache(maxEntries, maxBytes int) *valueCache {
+	if maxEntries <= 0 && maxBytes <= 0 {
+		return nil
+	}
+	return &valueCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// get returns a copy of the cached value of the record of key at offset.
+func (c *valueCache) get(key string, offset uint64) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok || elem.Value.(*cacheEntry).offset != offset {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(elem)
+	return append([]byte(nil), elem.Value.(*cacheEntry).value...), true
+}
+
+// add caches a copy of value as the value of the record of key at offset.
+func (c *valueCache) add(key string, offset uint64, value []byte) {
+	if c == nil || (c.maxBytes > 0 && len(value) > c.maxBytes) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeLocked(key)
+	entry := &cacheEntry{key: key, offset: offset, value: append([]byte(nil), value...)}
+	c.entries[key] = c.order.PushFront(entry)
+	c.bytes += len(value)
+	for (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.removeLocked(c.order.Back().Value.(*cacheEntry).key)
+	}
+}
+
+// remove drops the cached value of key, if any.
+func (c *valueCache) remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+}
+
+func (c *valueCache) removeLocked(key string) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(elem)
+	delete(c.entries, key)
+	c.bytes -= len(elem.Value.(*cacheEntry).value)
+}
+
+// clear drops every cached value, for when the index is rebuilt.
+func (c *valueCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// usage returns the number of cached values and their total size.
+func (c *valueCache) usage() (entries, bytes int) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len(), c.bytes
+}
+
+func (c *valueCache) counts() (hits, misses uint64) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValueCache(t *testing.T) {
+	kv := openTestKV(t, WithValueCache(2, 0))
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	mustInsert(t, kv, "c", "3")
+
+	wantValue(t, kv, "a", "1")
+	wantValue(t, kv, "a", "1")
+	if stats := kv.Stats(); stats.CacheHits != 1 || stats.CacheMisses != 1 {
+		t.Fatalf("hits %d, misses %d after two reads", stats.CacheHits, stats.CacheMisses)
+	}
+
+	// A cached value is not served once its key is written again.
+	mustInsert(t, kv, "a", "changed")
+	wantValue(t, kv, "a", "changed")
+
+	// b and c push a out, the least recently used.
+	wantValue(t, kv, "b", "2")
+	wantValue(t, kv, "c", "3")
+	status := kv.Status()
+	if status.CacheEntries != 2 || status.CacheBytes != 2 || status.CacheMaxEntries != 2 {
+		t.Fatalf("cache status = %d entries, %d bytes, max %d", status.CacheEntries, status.CacheBytes, status.CacheMaxEntries)
+	}
+	if _, ok := kv.cache.get("a", kv.pages["a"].offset); ok {
+		t.Fatal("least recently used value was not evicted")
+	}
+
+	if err := kv.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get("b"); err == nil {
+		t.Fatal("deleted key served from the cache")
+	}
+}
+
+func TestValueCacheByteLimit(t *testing.T) {
+	kv := openTestKV(t, WithValueCache(0, 10))
+	mustInsert(t, kv, "small", "12345")
+	mustInsert(t, kv, "large", "this value is over the limit")
+	wantValue(t, kv, "small", "12345")
+	wantValue(t, kv, "large", "this value is over the limit")
+	if entries, bytes := kv.cache.usage(); entries != 1 || bytes != 5 {
+		t.Fatalf("cache holds %d entries of %d bytes", entries, bytes)
+	}
+	if status := NewKV().Status(); status.CacheEntries != 0 || status.CacheMaxBytes != 0 {
+		t.Fatalf("cache status without a cache = %+v", status)
+	}
+}
+
+func BenchmarkGetCached(b *testing.B) {
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cache=%v", cached), func(b *testing.B) {
+			var opts []Option
+			if cached {
+				opts = append(opts, WithValueCache(1000, 0))
+			}
+			kv := openTestKV(b, opts...)
+			for i := 0; i < 100; i++ {
+				kv.Insert(fmt.Sprintf("key-%d", i), make([]byte, 1024))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := kv.Get(fmt.Sprintf("key-%d", i%100)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+// WriteCallback is called after a mutation has been written to the file. For
+// OpDelete value is nil.
+type WriteCallback func(op Op, key string, value []byte)
+
+// WithWriteCallback registers fn to be called synchronously after every
+// successful write, in the order the writes happen. It runs before the write
+// returns, so fn must not call back into the database. Multiple callbacks
+// may be registered and run in registration order.
+func WithWriteCallback(fn WriteCallback) Option {
+	return func(kv *KV) {
+		kv.writeCallbacks = append(kv.writeCallbacks, fn)
+	}
+}
+
+// notifyWrite is called by every write path once a write has succeeded. It
+// refreshes any pinned copy of the value before running the callbacks.
+func (kv *KV) notifyWrite(op Op, key string, value []byte) {
+	kv.updatePin(op, key, value)
+	for _, fn := range kv.writeCallbacks {
+		fn(op, key, value)
+	}
+}
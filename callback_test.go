@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWriteCallback(t *testing.T) {
+	var events, second []string
+	kv := openTestKV(t,
+		WithWriteCallback(func(op Op, key string, value []byte) {
+			events = append(events, fmt.Sprintf("%d %s %q", op, key, value))
+		}),
+		WithWriteCallback(func(op Op, key string, value []byte) {
+			// Registered later, so it runs after the first callback.
+			second = append(second, fmt.Sprintf("%d after %d events", op, len(events)))
+		}))
+
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	if err := kv.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "a", "3")
+	// Failed writes do not call back.
+	kv.Delete("missing")
+	if _, err := kv.CompareAndSwap("b", []byte("wrong"), []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	batch := kv.NewBatch()
+	batch.Put("c", []byte("4"))
+	batch.Delete("b")
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := fmt.Sprint([]string{
+		fmt.Sprintf("%d a %q", OpInsert, "1"),
+		fmt.Sprintf("%d b %q", OpInsert, "2"),
+		fmt.Sprintf("%d a %q", OpDelete, ""),
+		fmt.Sprintf("%d a %q", OpInsert, "3"),
+		// A batch is written as a patch, removals first.
+		fmt.Sprintf("%d b %q", OpDelete, ""),
+		fmt.Sprintf("%d c %q", OpInsert, "4"),
+	})
+	if got := fmt.Sprint(events); got != want {
+		t.Fatalf("callbacks saw\n%s\nwant\n%s", got, want)
+	}
+	if len(second) != 6 || second[5] != fmt.Sprintf("%d after 6 events", OpInsert) {
+		t.Fatalf("second callback saw %q", second)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+)
+
+// CompareAndSwap stores value under key only if the current value of key
+// equals old, or, when old is nil, only if key does not exist. It reports
+// whether the value was stored; a failed comparison is not an error. No
+// other write can happen between the comparison and the insert.
+func (kv *KV) CompareAndSwap(key string, old, value []byte) (bool, error) {
+	if err := kv.throttle(context.Background(), recordSize(key, nil, value)); err != nil {
+		return false, err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	current, ok, err := kv.lookup(key)
+	if err != nil {
+		return false, err
+	}
+	if old == nil {
+		if ok {
+			return false, nil
+		}
+	} else if !ok || !bytes.Equal(current, old) {
+		return false, nil
+	}
+
+	if err := kv.insert(key, nil, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// lookup returns the current value of key and whether it exists, for read
+// and write sequences that already hold the write lock.
+func (kv *KV) lookup(key string) ([]byte, bool, error) {
+	if kv.f == nil {
+		return nil, false, ErrDBNotOpen
+	}
+	if value, ok := kv.pinnedValue(key); ok {
+		return value, true, nil
+	}
+	page, ok := kv.pages[key]
+	if !ok || page.expired() {
+		return nil, false, nil
+	}
+	if err := kv.flush(); err != nil {
+		return nil, false, err
+	}
+	value, err := kv.readValue(key, page)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	kv := openTestKV(t)
+
+	swapped, err := kv.CompareAndSwap("a", nil, []byte("1"))
+	if err != nil || !swapped {
+		t.Fatalf("swap of an absent key = %v, %v", swapped, err)
+	}
+	swapped, err = kv.CompareAndSwap("a", nil, []byte("2"))
+	if err != nil || swapped {
+		t.Fatalf("swap expecting absence of a present key = %v, %v", swapped, err)
+	}
+	swapped, err = kv.CompareAndSwap("a", []byte("0"), []byte("2"))
+	if err != nil || swapped {
+		t.Fatalf("swap with a wrong old value = %v, %v", swapped, err)
+	}
+	wantValue(t, kv, "a", "1")
+
+	swapped, err = kv.CompareAndSwap("a", []byte("1"), []byte("2"))
+	if err != nil || !swapped {
+		t.Fatalf("swap with the right old value = %v, %v", swapped, err)
+	}
+	wantValue(t, kv, "a", "2")
+
+	swapped, err = kv.CompareAndSwap("b", []byte("1"), []byte("2"))
+	if err != nil || swapped {
+		t.Fatalf("swap of an absent key with an old value = %v, %v", swapped, err)
+	}
+	if kv.Exists("b") {
+		t.Fatal("failed swap created b")
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "n", "0")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; {
+				old, err := kv.Get("n")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				n, _ := strconv.Atoi(string(old))
+				swapped, err := kv.CompareAndSwap("n", old, []byte(strconv.Itoa(n+1)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if swapped {
+					j++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	wantValue(t, kv, "n", "400")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+var ErrChecksumMismatch = errors.New("record checksum mismatch")
+
+// crcSize is the length of the CRC32 that follows the key of a record with
+// checksumFlag set.
+const crcSize = 4
+
+// recordChecksum returns the CRC32 (IEEE) of a record's key followed by the
+// rest of its data section after the checksum: the metadata block, if any,
+// and the value as stored.
+func recordChecksum(key string, data []byte) uint32 {
+	crc := crc32.ChecksumIEEE([]byte(key))
+	return crc32.Update(crc, crc32.IEEETable, data)
+}
+
+// WithChecksumOnLoad makes Connect check the checksum of every record as it
+// scans the file. A mismatch is treated like any other unreadable record:
+// loading stops there, or fails with WithStrictLoad. Checksums are always
+// checked when a value is read.
+func WithChecksumOnLoad() Option {
+	return func(kv *KV) {
+		kv.checksumOnLoad = true
+	}
+}
+
+// readData reads the metadata block and value of the record described by
+// page, checking the record's checksum if it has one.
+func (kv *KV) readData(key string, page Page) (meta []byte, value []byte, err error) {
+	start := page.valueOffset() - page.metaSize
+	if page.checksummed {
+		start -= crcSize
+	}
+	buf := make([]byte, page.offset+page.size-start)
+	if _, err := kv.f.ReadAt(buf, int64(start)); err != nil {
+		return nil, nil, err
+	}
+
+	if page.checksummed {
+		want := binary.LittleEndian.Uint32(buf)
+		buf = buf[crcSize:]
+		if recordChecksum(key, buf) != want {
+			return nil, nil, fmt.Errorf("%w: key %s at offset %d", ErrChecksumMismatch, key, page.offset)
+		}
+	}
+	return buf[:page.metaSize], buf[page.metaSize:], nil
+}
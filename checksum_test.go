@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksumMismatch(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "value of a")
+	mustInsert(t, kv, "b", "value of b")
+	if err := kv.InsertWithMeta("m", []byte("v"), map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	corruptValue(t, kv, "a")
+
+	if _, err := kv.Get("a"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Get corrupt value: %v", err)
+	}
+	if _, err := kv.GetTo("a", make([]byte, 100)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("GetTo corrupt value: %v", err)
+	}
+	r, err := kv.GetReader("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("GetReader corrupt value: %v", err)
+	}
+	if err := kv.Scan(func(string, []byte) bool { return true }); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Scan over corrupt value: %v", err)
+	}
+	wantValue(t, kv, "b", "value of b")
+
+	// The metadata block is covered too.
+	page := kv.pages["m"]
+	kv.f.WriteAt([]byte{0xff}, int64(page.valueOffset()-1))
+	if _, _, err := kv.GetWithMeta("m"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("GetWithMeta corrupt metadata: %v", err)
+	}
+}
+
+func TestChecksumOnLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	corruptValue(t, kv, "b")
+	kv.Close()
+	os.Remove(indexPath(path))
+
+	// Without checking on load the bad record is indexed and fails on read.
+	kv = openTestKVAt(t, path, Options{ReadOnly: true})
+	if _, err := kv.Get("b"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Get corrupt value: %v", err)
+	}
+
+	kv = openTestKVAt(t, path, Options{ReadOnly: true, ChecksumOnLoad: true})
+	if kv.Exists("b") {
+		t.Fatal("corrupt record was loaded")
+	}
+	wantValue(t, kv, "a", "1")
+
+	err := NewKV(WithChecksumOnLoad(), WithStrictLoad()).ConnectWithOptions(path, Options{ReadOnly: true})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("strict load of corrupt record: %v", err)
+	}
+}
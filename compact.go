@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Compact rewrites the database file so it holds only the live record of each
+// key, reclaiming the space of overwritten and deleted keys. The new file is
+// written next to the old one and renamed over it, so a crash part way
+// through leaves the original intact. Values being written through Reserve
+// must be closed or aborted before compacting; until then Compact fails with
+// ErrReservationOpen.
+func (kv *KV) Compact() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.waitForExports()
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+
+	if err := kv.checkWritable(); err != nil {
+		return err
+	}
+	if len(kv.reservations) > 0 {
+		return ErrReservationOpen
+	}
+	if kv.staleGuard {
+		if err := kv.checkStale(); err != nil {
+			return err
+		}
+	}
+	if err := kv.flush(); err != nil {
+		return err
+	}
+
+	path := kv.f.Name()
+	tmp := path + ".compact"
+	f, err := createReplacement(tmp, kv.filePerm())
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(kv.fileHeader()); err != nil {
+		f.Close()
+		return err
+	}
+
+	offset := uint64(fileHeaderSize)
+	move := func(page Page) (Page, error) {
+		section := io.NewSectionReader(kv.f, int64(page.offset), int64(page.size))
+		if _, err := io.Copy(f, section); err != nil {
+			return page, err
+		}
+		page.offset = offset
+		offset += page.size
+		return page, nil
+	}
+	pages := make(map[string]Page, len(kv.pages))
+	for _, key := range kv.sortedKeys() {
+		page, err := move(kv.pages[key])
+		if err != nil {
+			f.Close()
+			return err
+		}
+		pages[key] = page
+	}
+	var buckets map[string]map[string]Page
+	err = kv.eachBucketPage(func(bucket, key string, page Page) error {
+		page, err := move(page)
+		if err != nil {
+			return err
+		}
+		if buckets == nil {
+			buckets = make(map[string]map[string]Page)
+		}
+		if buckets[bucket] == nil {
+			buckets[bucket] = make(map[string]Page)
+		}
+		buckets[bucket][key] = page
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// Opened again under its final name, so Path, Reopen and the sidecar
+	// index all refer to path rather than the temporary name.
+	f, err = os.OpenFile(path, os.O_RDWR, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	old := kv.f
+	kv.f = f
+	kv.pages = pages
+	kv.buckets = buckets
+	kv.prunePins()
+	kv.cache.clear()
+	kv.lastOffset = offset
+	kv.deadBytes = 0
+	return old.Close()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompactRefusesOpenReservation(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	vw, err := kv.Reserve("big", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Compact(); !errors.Is(err, ErrReservationOpen) {
+		t.Fatalf("Compact with open reservation: %v", err)
+	}
+	if err := kv.TruncateTo(uint64(fileHeaderSize)); !errors.Is(err, ErrReservationOpen) {
+		t.Fatalf("TruncateTo with open reservation: %v", err)
+	}
+	vw.Write([]byte("data"))
+	if err := vw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "big", "data")
+	wantValue(t, kv, "a", "1")
+}
+
+func TestCompact(t *testing.T) {
+	kv := openTestKV(t)
+	for i := 0; i < 100; i++ {
+		for key := 0; key < 10; key++ {
+			mustInsert(t, kv, fmt.Sprintf("key-%d", key), fmt.Sprintf("value %d of key %d", i, key))
+		}
+	}
+	for key := 5; key < 10; key++ {
+		if err := kv.Delete(fmt.Sprintf("key-%d", key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := fileSize(t, kv)
+
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after := fileSize(t, kv)
+	if after >= before/50 {
+		t.Fatalf("Compact shrank the file from %d to only %d bytes", before, after)
+	}
+	if uint64(after) != kv.lastOffset {
+		t.Fatalf("lastOffset %d after Compact, file is %d bytes", kv.lastOffset, after)
+	}
+	if err := kv.ValidateIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := filepath.Glob(kv.Path() + ".*"); len(matches) != 0 {
+		t.Fatalf("Compact left %q behind", matches)
+	}
+
+	check := func() {
+		t.Helper()
+		for key := 0; key < 10; key++ {
+			if key >= 5 {
+				if kv.Has(fmt.Sprintf("key-%d", key)) {
+					t.Fatalf("deleted key-%d is back", key)
+				}
+				continue
+			}
+			wantValue(t, kv, fmt.Sprintf("key-%d", key), fmt.Sprintf("value 99 of key %d", key))
+		}
+	}
+	check()
+	mustInsert(t, kv, "new", "v")
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	wantValue(t, kv, "new", "v")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+)
+
+// WithCompression adds a DEFLATE stage to the WithTransformers pipeline.
+// Values shorter than minSize are stored uncompressed, since compressing
+// tiny values costs CPU and often makes them larger; each record notes
+// whether it was compressed.
+func WithCompression(minSize int) Option {
+	return WithTransformers(compressor{minSize: minSize})
+}
+
+type compressor struct {
+	minSize int
+}
+
+func (c compressor) Skip(value []byte) bool {
+	return len(value) < c.minSize
+}
+
+func (c compressor) Encode(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(value); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c compressor) Decode(value []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(value))
+	defer r.Close()
+	return io.ReadAll(r)
+}
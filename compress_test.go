@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompressionThreshold(t *testing.T) {
+	kv := openTestKV(t, WithCompression(64))
+	values := map[string]string{
+		"tiny":  "x",
+		"below": strings.Repeat("a", 63),
+		"at":    strings.Repeat("b", 64),
+		"large": strings.Repeat("compressible ", 100),
+	}
+	for key, value := range values {
+		mustInsert(t, kv, key, value)
+	}
+
+	check := func() {
+		t.Helper()
+		for key, value := range values {
+			page := kv.pages[key]
+			compressed := page.transforms != 0
+			if want := len(value) >= 64; compressed != want {
+				t.Errorf("%s: compressed = %v, want %v", key, compressed, want)
+			}
+			if !compressed && page.valueSize != uint64(len(value)) {
+				t.Errorf("%s: stored %d bytes uncompressed for a %d byte value", key, page.valueSize, len(value))
+			}
+			wantValue(t, kv, key, value)
+		}
+	}
+	check()
+	if size := kv.pages["large"].valueSize; size >= uint64(len(values["large"])/10) {
+		t.Fatalf("large value compressed to %d bytes", size)
+	}
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
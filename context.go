@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetContext is like Get but gives up and returns ctx.Err() once ctx is done,
+// even if the read from storage has not finished. The read itself runs in a
+// separate goroutine so a slow or hung read cannot block the caller.
+func (kv *KV) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		value []byte
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		unlock := kv.readLock()
+		defer unlock()
+
+		if kv.f == nil {
+			done <- result{nil, ErrDBNotOpen}
+			return
+		}
+		if value, ok := kv.pinnedValue(key); ok {
+			kv.metrics.countGet(true)
+			done <- result{append([]byte(nil), value...), nil}
+			return
+		}
+		page, ok := kv.pages[key]
+		kv.metrics.countGet(ok)
+		if !ok {
+			done <- result{nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)}
+			return
+		}
+		value, err := kv.readValue(key, page)
+		done <- result{value, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		return r.value, r.err
+	}
+}
+
+// InsertContext is like Insert but returns ctx.Err() without writing anything
+// if ctx is done before the write starts, including while waiting for the
+// lock or for WithWriteRateLimit.
+func (kv *KV) InsertContext(ctx context.Context, key string, value []byte) error {
+	return kv.insertThrottled(ctx, key, nil, value)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetContext(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+
+	value, err := kv.GetContext(context.Background(), "a")
+	if err != nil || string(value) != "1" {
+		t.Fatalf("GetContext = %q, %v", value, err)
+	}
+	if _, err := kv.GetContext(context.Background(), "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetContext missing key: %v", err)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := kv.GetContext(cancelled, "a"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetContext with cancelled context: %v", err)
+	}
+}
+
+func TestGetContextAbandonsStuckRead(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+
+	// Holding the write lock stands in for storage that never answers.
+	kv.mu.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := kv.GetContext(ctx, "a")
+	elapsed := time.Since(start)
+	kv.mu.Unlock()
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetContext of a stuck read: %v", err)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("GetContext returned after %v", elapsed)
+	}
+	wantValue(t, kv, "a", "1")
+}
+
+func TestInsertContext(t *testing.T) {
+	kv := openTestKV(t)
+	if err := kv.InsertContext(context.Background(), "a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "a", "1")
+
+	size := fileSize(t, kv)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := kv.InsertContext(cancelled, "b", []byte("2")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("InsertContext with cancelled context: %v", err)
+	}
+	if kv.Exists("b") || fileSize(t, kv) != size {
+		t.Fatal("cancelled insert was written")
+	}
+}
+
+func TestInsertContextCancelledWhileWaiting(t *testing.T) {
+	kv := openTestKV(t)
+
+	kv.mu.Lock()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- kv.InsertContext(ctx, "a", []byte("1")) }()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	kv.mu.Unlock()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("InsertContext cancelled while waiting for the lock: %v", err)
+	}
+	if kv.Exists("a") {
+		t.Fatal("cancelled insert was written")
+	}
+}
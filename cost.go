@@ -0,0 +1,30 @@
+package main
+
+// CompactCostEstimate describes how much work compacting the database would
+// take, for deciding whether it is worth doing now.
+type CompactCostEstimate struct {
+	// LiveRecords is the number of records that would be rewritten.
+	LiveRecords int
+	// LiveBytes is the size of those records, which compaction both reads
+	// and writes.
+	LiveBytes uint64
+	// FileBytes is the current size of the database file.
+	FileBytes uint64
+	// ReclaimableBytes is how much smaller the file would get.
+	ReclaimableBytes uint64
+}
+
+// CompactCost estimates the cost of compaction from the in-memory index and
+// the file size, without reading any records. It is computed like Stats.
+func (kv *KV) CompactCost() CompactCostEstimate {
+	unlock := kv.readLock()
+	defer unlock()
+
+	stats := kv.stats()
+	return CompactCostEstimate{
+		LiveRecords:      stats.Keys,
+		LiveBytes:        stats.LiveBytes,
+		FileBytes:        stats.FileBytes,
+		ReclaimableBytes: stats.DeadBytes,
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestCompactCost(t *testing.T) {
+	kv := openTestKV(t)
+	if cost := kv.CompactCost(); cost != (CompactCostEstimate{FileBytes: uint64(fileHeaderSize)}) {
+		t.Fatalf("CompactCost of empty database = %+v", cost)
+	}
+
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "a", "22")
+	mustInsert(t, kv, "b", "333")
+	if err := kv.InsertWithMeta("m", []byte("v"), map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var live uint64
+	for _, page := range kv.pages {
+		live += page.size
+	}
+	cost := kv.CompactCost()
+	if cost.LiveRecords != 3 || cost.LiveBytes != live {
+		t.Fatalf("CompactCost = %+v, want 3 records of %d bytes", cost, live)
+	}
+	if cost.FileBytes != uint64(fileHeaderSize)+cost.LiveBytes+cost.ReclaimableBytes {
+		t.Fatalf("CompactCost = %+v does not add up", cost)
+	}
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if got := kv.CompactCost(); got.FileBytes != cost.FileBytes-cost.ReclaimableBytes || got.ReclaimableBytes != 0 {
+		t.Fatalf("CompactCost after Compact = %+v", got)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// Increment adds delta to the counter stored under key and returns the new
+// count. Counters are stored as 8 byte little endian int64 values; a missing
+// key counts from 0, and any other value size is an error. The read and the
+// write happen under one lock, so concurrent increments are never lost.
+func (kv *KV) Increment(key string, delta int64) (int64, error) {
+	if err := kv.throttle(context.Background(), recordSize(key, nil, make([]byte, 8))); err != nil {
+		return 0, err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	current, ok, err := kv.lookup(key)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if ok {
+		if len(current) != 8 {
+			return 0, fmt.Errorf("value of key %s is %d bytes, not an 8 byte counter", key, len(current))
+		}
+		n = int64(binary.LittleEndian.Uint64(current))
+	}
+
+	n += delta
+	value := make([]byte, 8)
+	binary.LittleEndian.PutUint64(value, uint64(n))
+	if err := kv.insert(key, nil, value); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+)
+
+func TestIncrement(t *testing.T) {
+	kv := openTestKV(t)
+
+	for _, tc := range []struct {
+		delta, want int64
+	}{{1, 1}, {5, 6}, {-10, -4}} {
+		n, err := kv.Increment("n", tc.delta)
+		if err != nil || n != tc.want {
+			t.Fatalf("Increment(%d) = %d, %v, want %d", tc.delta, n, err, tc.want)
+		}
+	}
+	value, err := kv.Get("n")
+	if err != nil || len(value) != 8 || int64(binary.LittleEndian.Uint64(value)) != -4 {
+		t.Fatalf("stored counter = %v, %v", value, err)
+	}
+
+	mustInsert(t, kv, "s", "short")
+	if _, err := kv.Increment("s", 1); err == nil {
+		t.Fatal("incremented a 5 byte value")
+	}
+	wantValue(t, kv, "s", "short")
+}
+
+func TestIncrementConcurrent(t *testing.T) {
+	kv := openTestKV(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := kv.Increment("n", 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n, err := kv.Increment("n", 0); err != nil || n != 800 {
+		t.Fatalf("count = %d, %v, want 800", n, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// csvBatchSize is the number of rows ImportCSV writes at a time.
+const csvBatchSize = 1000
+
+// WithSkipMalformedCSV makes ImportCSV skip rows it cannot parse, or that are
+// too short to hold the key and value columns, instead of aborting.
+func WithSkipMalformedCSV() Option {
+	return func(kv *KV) {
+		kv.skipMalformedCSV = true
+	}
+}
+
+// ImportCSV reads CSV rows from r and stores the keyCol field of each row
+// under the valueCol field, returning the number of rows imported. A header
+// row is imported like any other, so strip it first if there is one. Values
+// are stored as the UTF-8 text of the field; CSV is not suited to binary
+// values. Rows are written in batches, so an error part way through leaves
+// the batches before it imported.
+func (kv *KV) ImportCSV(r io.Reader, keyCol, valueCol int) (int, error) {
+	if keyCol < 0 || valueCol < 0 {
+		return 0, fmt.Errorf("invalid csv columns %d and %d", keyCol, valueCol)
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	imported, pending := 0, 0
+	batch := make(map[string][]byte)
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+		if err := kv.ApplyPatch(Patch{Set: batch}); err != nil {
+			return err
+		}
+		imported += pending
+		pending = 0
+		batch = make(map[string][]byte)
+		return nil
+	}
+
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && kv.skipMalformedCSV {
+			continue
+		}
+		if err != nil {
+			return imported, err
+		}
+
+		if keyCol >= len(row) || valueCol >= len(row) {
+			if kv.skipMalformedCSV {
+				continue
+			}
+			line, _ := cr.FieldPos(0)
+			return imported, fmt.Errorf("csv line %d: row has %d fields", line, len(row))
+		}
+
+		// A later row for the same key replaces the earlier one, just as
+		// it would if the rows were inserted one at a time.
+		batch[row[keyCol]] = []byte(row[valueCol])
+		pending++
+		if pending == csvBatchSize {
+			if err := flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return imported, err
+	}
+	return imported, nil
+}
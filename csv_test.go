@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testCSV = `id,name,city
+1,alice,"Portland, OR"
+2,bob,"multi
+line"
+3,"carol ""cc""",Paris
+1,alice,Seattle
+`
+
+func TestImportCSV(t *testing.T) {
+	kv := openTestKV(t)
+	n, err := kv.ImportCSV(strings.NewReader(testCSV), 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("imported %d rows, want 5", n)
+	}
+	// The header row is imported like any other, and a later row for the
+	// same key wins.
+	wantValue(t, kv, "id", "city")
+	wantValue(t, kv, "1", "Seattle")
+	wantValue(t, kv, "2", "multi\nline")
+	wantValue(t, kv, "3", "Paris")
+
+	n, err = kv.ImportCSV(strings.NewReader(testCSV), 1, 0)
+	if err != nil || n != 5 {
+		t.Fatalf("ImportCSV by name = %d, %v", n, err)
+	}
+	wantValue(t, kv, `carol "cc"`, "3")
+}
+
+func TestImportCSVMalformedRows(t *testing.T) {
+	const malformed = "a,1\nshort\nb,2\nc,va\"lue\nd,4\n"
+
+	kv := openTestKV(t)
+	n, err := kv.ImportCSV(strings.NewReader(malformed), 0, 1)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("ImportCSV of a short row = %d, %v", n, err)
+	}
+	if kv.Exists("b") {
+		t.Fatal("rows after the malformed one were imported")
+	}
+
+	kv = openTestKV(t, WithSkipMalformedCSV())
+	n, err = kv.ImportCSV(strings.NewReader(malformed), 0, 1)
+	if err != nil || n != 3 {
+		t.Fatalf("ImportCSV skipping malformed rows = %d, %v", n, err)
+	}
+	wantValue(t, kv, "a", "1")
+	wantValue(t, kv, "b", "2")
+	wantValue(t, kv, "d", "4")
+	if kv.Exists("c") || kv.Exists("short") {
+		t.Fatal("malformed row was imported")
+	}
+}
+
+func TestImportCSVNegativeColumn(t *testing.T) {
+	kv := openTestKV(t, WithSkipMalformedCSV())
+	for _, cols := range [][2]int{{-1, 1}, {0, -1}} {
+		n, err := kv.ImportCSV(strings.NewReader(testCSV), cols[0], cols[1])
+		if err == nil || n != 0 {
+			t.Fatalf("ImportCSV with columns %v = %d, %v", cols, n, err)
+		}
+	}
+	if kv.Len() != 0 {
+		t.Fatalf("imported %d keys", kv.Len())
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+// Cursor iterates over the keys of a database in sorted order, for callers
+// that prefer pulling values to the callbacks of Scan. The set of keys is
+// fixed when the cursor is created; each value is read when Next reaches its
+// key, so it is the value current at that moment. Keys deleted in between
+// are skipped. The database is only locked inside Next, so writes may go on
+// while a cursor is in use.
+type Cursor struct {
+	kv    *KV
+	keys  []string
+	pos   int
+	key   string
+	value []byte
+	err   error
+}
+
+// Cursor returns a cursor positioned before the first key.
+func (kv *KV) Cursor() *Cursor {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return &Cursor{kv: kv, err: ErrDBNotOpen}
+	}
+	return &Cursor{kv: kv, keys: kv.sortedKeys()}
+}
+
+// Next advances to the next key and reads its value. It returns false once
+// the keys are exhausted or reading fails; Err tells the two apart.
+func (c *Cursor) Next() bool {
+	c.key, c.value = "", nil
+	if c.err != nil {
+		return false
+	}
+
+	kv := c.kv
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		c.err = ErrDBNotOpen
+		return false
+	}
+	for c.pos < len(c.keys) {
+		key := c.keys[c.pos]
+		c.pos++
+
+		if value, ok := kv.pinnedValue(key); ok {
+			c.key, c.value = key, append([]byte(nil), value...)
+			return true
+		}
+		page, ok := kv.pages[key]
+		if !ok || page.expired() {
+			continue
+		}
+		value, err := kv.readValue(key, page)
+		if err != nil {
+			c.err = err
+			return false
+		}
+		c.key, c.value = key, value
+		return true
+	}
+	return false
+}
+
+// Key returns the key the cursor is at.
+func (c *Cursor) Key() string {
+	return c.key
+}
+
+// Value returns the value of the key the cursor is at.
+func (c *Cursor) Value() []byte {
+	return c.value
+}
+
+// Err returns the error that stopped iteration, or nil if Next returned
+// false because every key was visited.
+func (c *Cursor) Err() error {
+	return c.err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cursorKeys drains c, returning the keys and values it visited.
+func cursorKeys(c *Cursor) (keys, values []string) {
+	for c.Next() {
+		keys = append(keys, c.Key())
+		values = append(values, string(c.Value()))
+	}
+	return keys, values
+}
+
+func TestCursor(t *testing.T) {
+	kv := openTestKV(t)
+	c := kv.Cursor()
+	if keys, _ := cursorKeys(c); len(keys) != 0 || c.Err() != nil {
+		t.Fatalf("cursor over an empty database visited %q, err %v", keys, c.Err())
+	}
+
+	for _, key := range []string{"c", "a", "d", "b"} {
+		mustInsert(t, kv, key, strings.ToUpper(key))
+	}
+	c = kv.Cursor()
+	keys, values := cursorKeys(c)
+	if strings.Join(keys, ",") != "a,b,c,d" || strings.Join(values, ",") != "A,B,C,D" {
+		t.Fatalf("cursor visited %q = %q", keys, values)
+	}
+	if c.Err() != nil {
+		t.Fatal(c.Err())
+	}
+	if c.Next() || c.Key() != "" || c.Value() != nil {
+		t.Fatal("exhausted cursor moved on")
+	}
+}
+
+func TestCursorDuringWrites(t *testing.T) {
+	kv := openTestKV(t)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		mustInsert(t, kv, key, "1")
+	}
+
+	c := kv.Cursor()
+	if !c.Next() || c.Key() != "a" {
+		t.Fatalf("first key %q", c.Key())
+	}
+	if err := kv.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "c", "2")
+	mustInsert(t, kv, "bb", "1")
+
+	keys, values := cursorKeys(c)
+	if strings.Join(keys, ",") != "c,d" || strings.Join(values, ",") != "2,1" {
+		t.Fatalf("cursor visited %q = %q", keys, values)
+	}
+	if c.Err() != nil {
+		t.Fatal(c.Err())
+	}
+}
+
+func TestCursorErr(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	corruptValue(t, kv, "b")
+
+	c := kv.Cursor()
+	keys, _ := cursorKeys(c)
+	if len(keys) != 1 || !errors.Is(c.Err(), ErrChecksumMismatch) {
+		t.Fatalf("cursor visited %q, err %v", keys, c.Err())
+	}
+
+	c = kv.Cursor()
+	kv.Close()
+	if c.Next() || !errors.Is(c.Err(), ErrDBNotOpen) {
+		t.Fatalf("Next after Close: err %v", c.Err())
+	}
+	if c := kv.Cursor(); c.Next() || !errors.Is(c.Err(), ErrDBNotOpen) {
+		t.Fatalf("cursor of a closed database: err %v", c.Err())
+	}
+}
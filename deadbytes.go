@@ -0,0 +1,25 @@
+package main
+
+// DeadBytes returns the number of bytes in the file taken up by records that
+// have been overwritten or deleted, along with tombstones and abandoned
+// reservations. This is the space Compact would reclaim.
+func (kv *KV) DeadBytes() uint64 {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.deadBytes
+}
+
+// retirePage counts the record currently indexed for key, if any, as dead,
+// and drops its cached value. It is called before the key is overwritten or
+// removed from the index.
+func (kv *KV) retirePage(key string) {
+	kv.retireIn(kv.pages, key)
+}
+
+// retireIn is retirePage for the index of any key space.
+func (kv *KV) retireIn(pages map[string]Page, key string) {
+	if page, ok := pages[key]; ok {
+		kv.deadBytes += page.size
+		kv.cache.remove(key)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestDeadBytes(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	if kv.DeadBytes() != 0 {
+		t.Fatalf("DeadBytes before any overwrite = %d", kv.DeadBytes())
+	}
+
+	old := kv.pages["a"].size
+	mustInsert(t, kv, "a", "longer value")
+	wantValue(t, kv, "a", "longer value")
+	if kv.DeadBytes() != old {
+		t.Fatalf("DeadBytes after overwrite = %d, want %d", kv.DeadBytes(), old)
+	}
+
+	// Deleting counts the deleted record and the tombstone itself.
+	before, size := kv.lastOffset, kv.pages["b"].size
+	if err := kv.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	want := old + size + (kv.lastOffset - before)
+	if kv.DeadBytes() != want {
+		t.Fatalf("DeadBytes after delete = %d, want %d", kv.DeadBytes(), want)
+	}
+
+	// Loading the file again finds the same dead space.
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if kv.DeadBytes() != want {
+		t.Fatalf("DeadBytes after Reopen = %d, want %d", kv.DeadBytes(), want)
+	}
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if kv.DeadBytes() != 0 {
+		t.Fatalf("DeadBytes after Compact = %d", kv.DeadBytes())
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+// SetDefaults inserts each of defaults whose key is not already present,
+// leaving existing values alone. It is meant for seeding initial values on
+// first run without clobbering values changed since.
+func (kv *KV) SetDefaults(defaults map[string][]byte) error {
+	throttled := 0
+	for key, value := range defaults {
+		throttled += recordSize(key, nil, value)
+	}
+	if err := kv.throttle(context.Background(), throttled); err != nil {
+		return err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		if _, ok := kv.pages[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := kv.insert(key, nil, defaults[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSetDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	defaults := map[string][]byte{"host": []byte("localhost"), "port": []byte("8080"), "debug": []byte("false")}
+
+	kv := openTestKVAt(t, path, Options{})
+	if err := kv.SetDefaults(defaults); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range defaults {
+		wantValue(t, kv, key, string(value))
+	}
+	mustInsert(t, kv, "port", "9090")
+	kv.Close()
+
+	kv = openTestKVAt(t, path, Options{})
+	end := kv.lastOffset
+	if err := kv.SetDefaults(defaults); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "port", "9090")
+	wantValue(t, kv, "host", "localhost")
+	if kv.lastOffset != end {
+		t.Fatalf("SetDefaults on a seeded database wrote %d bytes", kv.lastOffset-end)
+	}
+
+	// Only missing keys are seeded.
+	defaults["timeout"] = []byte("30s")
+	if err := kv.SetDefaults(defaults); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "timeout", "30s")
+	wantValue(t, kv, "port", "9090")
+	if kv.Len() != 4 {
+		t.Fatalf("Len = %d, want 4", kv.Len())
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// Delete removes key by appending a tombstone record for it, so the key stays
+// deleted when the file is loaded again. The space held by its earlier
+// records is reclaimed by compaction.
+func (kv *KV) Delete(key string) error {
+	if err := kv.throttle(context.Background(), recordSize(key, nil, nil)); err != nil {
+		return err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.remove(key)
+}
+
+func (kv *KV) remove(key string) error {
+	return kv.removeIn("", key)
+}
+
+// removeIn is remove for the key space of the named bucket, "" being the
+// default one. The tombstone is tagged with the bucket so that loading the
+// file removes the key from the right index.
+func (kv *KV) removeIn(bucket, key string) error {
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	if err := kv.checkWritable(); err != nil {
+		return err
+	}
+	if kv.staleGuard {
+		if err := kv.checkStale(); err != nil {
+			return err
+		}
+	}
+
+	pages := kv.bucketPages(bucket, false)
+	if _, ok := pages[key]; !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+
+	var meta []byte
+	if bucket != "" {
+		meta = encodeMeta(map[string]string{bucketMeta: bucket})
+	}
+	record := encodeTombstone(key, meta)
+	stored := kv.storedRecord(record)
+
+	if err := kv.appendRecords(stored); err != nil {
+		return err
+	}
+	kv.retireIn(pages, key)
+	delete(pages, key)
+	kv.deadBytes += uint64(len(stored))
+	kv.lastOffset += uint64(len(stored))
+	kv.metrics.countDelete(1, len(stored))
+	if err := kv.syncWrite(); err != nil {
+		return err
+	}
+	if bucket == "" {
+		kv.notifyWrite(OpDelete, key, nil)
+	}
+
+	return kv.recordOp(OpDelete, record)
+}
+
+// encodeTombstone lays out the record marking key as deleted: a record with
+// an empty value, the given metadata block and tombstoneFlag set.
+func encodeTombstone(key string, meta []byte) []byte {
+	record := encodePage(key, 0, meta, nil)
+	valueSize := binary.LittleEndian.Uint64(record[8:16])
+	binary.LittleEndian.PutUint64(record[8:16], valueSize|tombstoneFlag)
+	return record
+}
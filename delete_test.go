@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDelete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	if err := kv.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get("a"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get deleted key: %v", err)
+	}
+	if err := kv.Delete("a"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Delete deleted key: %v", err)
+	}
+	if err := kv.Delete("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Delete missing key: %v", err)
+	}
+	kv.Close()
+
+	// The tombstone keeps the key deleted when the file is scanned again.
+	for _, sidecar := range []bool{true, false} {
+		if !sidecar {
+			if err := os.Remove(indexPath(path)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		kv = openTestKVAt(t, path, Options{})
+		if kv.Has("a") {
+			t.Fatalf("deleted key came back (sidecar %v)", sidecar)
+		}
+		wantValue(t, kv, "b", "2")
+		kv.Close()
+	}
+
+	// A key can be inserted again after being deleted.
+	kv = openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "3")
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "a", "3")
+}
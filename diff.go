@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+// Diff compares the live contents of two databases. added holds keys only in
+// current, removed keys only in base, and modified keys present in both with
+// different values. Each list is sorted.
+func Diff(base, current *KV) (added, modified, removed []string, err error) {
+	unlock := readLockBoth(base, current)
+	defer unlock()
+	return diff(base, current)
+}
+
+// readLockBoth takes the read locks of two databases, which may be the same
+// one, and returns a function releasing both. The locks are always taken in
+// address order, so that concurrent calls with the databases swapped cannot
+// deadlock; readLock may take a write lock, and a waiting writer blocks new
+// readers in any case.
+func readLockBoth(a, b *KV) (unlock func()) {
+	if a == b {
+		return a.readLock()
+	}
+	if uintptr(unsafe.Pointer(b)) < uintptr(unsafe.Pointer(a)) {
+		a, b = b, a
+	}
+	unlockA := a.readLock()
+	unlockB := b.readLock()
+	return func() {
+		unlockB()
+		unlockA()
+	}
+}
+
+func diff(base, current *KV) (added, modified, removed []string, err error) {
+	for _, key := range current.sortedKeys() {
+		basePage, ok := base.pages[key]
+		if !ok || basePage.expired() {
+			added = append(added, key)
+			continue
+		}
+		currentPage := current.pages[key]
+		sameEncoding := basePage.transforms == 0 && currentPage.transforms == 0
+		if sameEncoding && basePage.valueSize != currentPage.valueSize {
+			modified = append(modified, key)
+			continue
+		}
+
+		baseValue, err := base.readValue(key, basePage)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		currentValue, err := current.readValue(key, currentPage)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !bytes.Equal(baseValue, currentValue) {
+			modified = append(modified, key)
+		}
+	}
+
+	for _, key := range base.sortedKeys() {
+		if page, ok := current.pages[key]; !ok || page.expired() {
+			removed = append(removed, key)
+		}
+	}
+	return added, modified, removed, nil
+}
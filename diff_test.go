@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestDiff(t *testing.T) {
+	base := openTestKV(t)
+	current := openTestKV(t, WithCompression(0))
+	mustInsert(t, base, "same", "v")
+	mustInsert(t, current, "same", "v")
+	mustInsert(t, base, "changed", "old")
+	mustInsert(t, current, "changed", "new")
+	mustInsert(t, base, "resized", "v")
+	mustInsert(t, current, "resized", "longer")
+	mustInsert(t, base, "gone", "v")
+	mustInsert(t, current, "new", "v")
+
+	added, modified, removed, err := Diff(base, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(added) != "[new]" || fmt.Sprint(modified) != "[changed resized]" || fmt.Sprint(removed) != "[gone]" {
+		t.Fatalf("Diff = %q %q %q", added, modified, removed)
+	}
+
+	added, modified, removed, err = Diff(base, base)
+	if err != nil || len(added)+len(modified)+len(removed) != 0 {
+		t.Fatalf("Diff with itself = %q %q %q, %v", added, modified, removed, err)
+	}
+}
+
+func TestReadLockBothOrder(t *testing.T) {
+	low, high := openTestKV(t), openTestKV(t)
+	if uintptr(unsafe.Pointer(high)) < uintptr(unsafe.Pointer(low)) {
+		low, high = high, low
+	}
+
+	// Whatever the argument order, readLockBoth must wait for the lower lock
+	// before taking the higher one. Taking high first and then waiting for
+	// low is how Diff(a, b) and Diff(b, a) deadlock.
+	low.mu.Lock()
+	locked := make(chan func())
+	go func() { locked <- readLockBoth(high, low) }()
+	time.Sleep(20 * time.Millisecond)
+	if !high.mu.TryLock() {
+		low.mu.Unlock()
+		(<-locked)()
+		t.Fatal("readLockBoth took the higher lock while waiting for the lower one")
+	}
+	high.mu.Unlock()
+	low.mu.Unlock()
+	(<-locked)()
+}
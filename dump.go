@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DumpSummary writes a human-readable summary of the database to w for
+// attaching to bug reports: the file format version and record header layout,
+// file statistics and then a sample of keys with their value sizes. Values
+// themselves are never included. Output stops before it would exceed
+// maxBytes.
+func (kv *KV) DumpSummary(w io.Writer, maxBytes int) error {
+	unlock := kv.readLock()
+	defer unlock()
+
+	stats := kv.stats()
+
+	var b strings.Builder
+	write := func(line string) bool {
+		if b.Len()+len(line) > maxBytes {
+			return false
+		}
+		b.WriteString(line)
+		return true
+	}
+
+	version, layout := formatVersion, "fixed"
+	if kv.varintHeaders {
+		version, layout = varintFormatVersion, "varint"
+	}
+
+	keys := kv.sortedKeys()
+	ok := write(fmt.Sprintf("format: version %d, %s record headers\n", version, layout)) &&
+		write(fmt.Sprintf("keys: %d\n", len(keys))) &&
+		write(fmt.Sprintf("file size: %d\n", stats.FileBytes)) &&
+		write(fmt.Sprintf("live bytes: %d\n", stats.LiveBytes)) &&
+		write(fmt.Sprintf("dead bytes: %d\n", stats.DeadBytes))
+	if ok {
+		for i, key := range keys {
+			if !write(fmt.Sprintf("%q: %d bytes\n", key, kv.pages[key].valueSize)) {
+				write(fmt.Sprintf("... %d more keys\n", len(keys)-i))
+				break
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDumpSummary(t *testing.T) {
+	kv := openTestKV(t)
+	for i := 0; i < 100; i++ {
+		mustInsert(t, kv, fmt.Sprintf("key-%03d", i), "secret value")
+	}
+
+	var full strings.Builder
+	if err := kv.DumpSummary(&full, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(full.String(), "\n")
+	if lines[0] != "format: version 1, fixed record headers" {
+		t.Fatalf("first line = %q", lines[0])
+	}
+	if lines[1] != "keys: 100" {
+		t.Fatalf("second line = %q", lines[1])
+	}
+	if strings.Contains(full.String(), "secret") {
+		t.Fatal("summary includes a value")
+	}
+	if !strings.Contains(full.String(), `"key-099": 12 bytes`) {
+		t.Fatal("summary is missing the last key")
+	}
+
+	var short strings.Builder
+	if err := kv.DumpSummary(&short, 200); err != nil {
+		t.Fatal(err)
+	}
+	if short.Len() > 200 {
+		t.Fatalf("summary is %d bytes, limit is 200", short.Len())
+	}
+	if !strings.HasPrefix(full.String(), short.String()) {
+		t.Fatalf("truncated summary is not a prefix of the full one:\n%s", short.String())
+	}
+
+	varint := openTestKV(t, WithVarintHeaders())
+	var b strings.Builder
+	varint.DumpSummary(&b, 1<<10)
+	if !strings.HasPrefix(b.String(), "format: version 2, varint record headers\n") {
+		t.Fatalf("varint summary starts %q", b.String())
+	}
+}
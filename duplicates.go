@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Duplicates scans every physical record in the file and returns, for each key
+// with more than one record, how many versions of it the log holds. All but
+// one of those versions are dead space until the log is compacted. The scan
+// stops where loading stopped, and a record that no longer fits in the file
+// is reported as an error rather than read. Records of a named Bucket are
+// left out.
+func (kv *KV) Duplicates() (map[string]int, error) {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return nil, ErrDBNotOpen
+	}
+	info, err := kv.f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := uint64(info.Size())
+	if kv.lastOffset < size {
+		size = kv.lastOffset
+	}
+
+	counts := make(map[string]int)
+	offset := uint64(fileHeaderSize)
+	for offset < size {
+		keySize, dataSize, flags, headerSize, err := kv.readPageHeader(int64(offset))
+		if err != nil {
+			return nil, fmt.Errorf("corrupt record at offset %d: %w", offset, err)
+		}
+		remaining := size - offset
+		if headerSize > remaining || keySize > remaining-headerSize || dataSize > remaining-headerSize-keySize {
+			return nil, fmt.Errorf("corrupt record at offset %d: %w", offset, io.ErrUnexpectedEOF)
+		}
+		if keySize > kv.maxKeySize {
+			return nil, fmt.Errorf("corrupt record at offset %d: %w", offset, ErrKeyTooLarge)
+		}
+		if flags&(reservedFlag|tombstoneFlag) == 0 {
+			bucket, err := kv.recordBucket(offset+headerSize+keySize, dataSize, flags)
+			if err != nil {
+				return nil, fmt.Errorf("corrupt record at offset %d: %w", offset, err)
+			}
+			if bucket == "" {
+				keyBuf := make([]byte, keySize)
+				if _, err := kv.f.ReadAt(keyBuf, int64(offset+headerSize)); err != nil {
+					return nil, err
+				}
+				counts[string(keyBuf)]++
+			}
+		}
+		offset += headerSize + keySize + dataSize
+	}
+
+	for key, n := range counts {
+		if n < 2 {
+			delete(counts, key)
+		}
+	}
+	return counts, nil
+}
+
+// recordBucket returns the bucket named in the metadata block of the record
+// whose data, dataSize bytes long, starts at offset, or "" if it has none.
+func (kv *KV) recordBucket(offset, dataSize, flags uint64) (string, error) {
+	if flags&metaFlag == 0 {
+		return "", nil
+	}
+	if flags&checksumFlag != 0 {
+		if dataSize < crcSize {
+			return "", errors.New("checksum overruns record")
+		}
+		offset += crcSize
+		dataSize -= crcSize
+	}
+	if dataSize < 8 {
+		return "", errors.New("metadata block overruns record")
+	}
+	sizeBuf := make([]byte, 8)
+	if _, err := kv.f.ReadAt(sizeBuf, int64(offset)); err != nil {
+		return "", err
+	}
+	metaSize := binary.LittleEndian.Uint64(sizeBuf)
+	if metaSize > dataSize-8 {
+		return "", errors.New("metadata block overruns record")
+	}
+	block := make([]byte, 8+metaSize)
+	if _, err := kv.f.ReadAt(block, int64(offset)); err != nil {
+		return "", err
+	}
+	return metaBucket(block), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDuplicates(t *testing.T) {
+	kv := openTestKV(t)
+	for i := 0; i < 3; i++ {
+		mustInsert(t, kv, "a", fmt.Sprint(i))
+	}
+	mustInsert(t, kv, "b", "1")
+	mustInsert(t, kv, "b", "2")
+	mustInsert(t, kv, "c", "1")
+	mustInsert(t, kv, "d", "1")
+	mustInsert(t, kv, "d", "2")
+	// Tombstones are not versions of the key.
+	if err := kv.Delete("d"); err != nil {
+		t.Fatal(err)
+	}
+
+	dups, err := kv.Duplicates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(dups); got != "map[a:3 b:2 d:2]" {
+		t.Fatalf("Duplicates = %s", got)
+	}
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if dups, err := kv.Duplicates(); err != nil || len(dups) != 0 {
+		t.Fatalf("Duplicates after Compact = %v, %v", dups, err)
+	}
+
+	kv.Close()
+	if _, err := kv.Duplicates(); !errors.Is(err, ErrDBNotOpen) {
+		t.Fatalf("Duplicates after Close: %v", err)
+	}
+}
+
+func TestDuplicatesGarbageHeader(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "a", "2")
+	kv.Close()
+	os.Remove(indexPath(path))
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(fixedHeader(1<<62, 1<<40))
+	f.Write(make([]byte, 32))
+	f.Close()
+
+	kv = openTestKVAt(t, path, Options{ReadOnly: true})
+	dups, err := kv.Duplicates()
+	if err != nil || dups["a"] != 2 {
+		t.Fatalf("Duplicates = %v, %v", dups, err)
+	}
+
+	// A record that changed under the index is reported, not read.
+	kv.lastOffset = uint64(fileSize(t, kv))
+	if _, err := kv.Duplicates(); err == nil {
+		t.Fatal("Duplicates read past a garbage header")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// wantSameContents fails the test unless a and b hold the same keys and values.
+func wantSameContents(t *testing.T, a, b *KV) {
+	t.Helper()
+	added, modified, removed, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added)+len(modified)+len(removed) != 0 {
+		t.Fatalf("contents differ: added %q, modified %q, removed %q", added, modified, removed)
+	}
+}
+
+func TestCompactToWriter(t *testing.T) {
+	kv := openTestKV(t)
+	for i := 0; i < 3; i++ {
+		mustInsert(t, kv, "a", string(rune('0'+i)))
+		mustInsert(t, kv, "b", "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	}
+	mustInsert(t, kv, "deleted", "v")
+	if err := kv.Delete("deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.InsertWithMeta("m", []byte("v"), map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	var backup bytes.Buffer
+	if err := kv.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compress := range []bool{false, true} {
+		var out bytes.Buffer
+		if err := kv.CompactToWriter(&out, compress); err != nil {
+			t.Fatal(err)
+		}
+		if out.Len() >= backup.Len() {
+			t.Fatalf("compress %v: compacted copy is %d bytes, full backup %d", compress, out.Len(), backup.Len())
+		}
+
+		var r io.Reader = &out
+		if compress {
+			zr, err := gzip.NewReader(&out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = zr
+		}
+		path := filepath.Join(t.TempDir(), "restored.db")
+		if err := Restore(path, r); err != nil {
+			t.Fatalf("compress %v: Restore: %v", compress, err)
+		}
+		restored := openTestKVAt(t, path, Options{ReadOnly: true})
+		wantSameContents(t, kv, restored)
+		if err := restored.Verify(); err != nil {
+			t.Fatalf("compress %v: %v", compress, err)
+		}
+	}
+}
+
+func TestExtractTo(t *testing.T) {
+	kv := openTestKV(t)
+	for _, key := range []string{"tenant-a/1", "tenant-a/2", "tenant-b/1", "tenant-ab/1"} {
+		mustInsert(t, kv, key, key+" value")
+	}
+	mustInsert(t, kv, "tenant-a/1", "latest")
+	end := kv.lastOffset
+
+	path := filepath.Join(t.TempDir(), "tenant-a.db")
+	if err := kv.ExtractTo(path, func(key string) bool { return strings.HasPrefix(key, "tenant-a/") }); err != nil {
+		t.Fatal(err)
+	}
+	if kv.lastOffset != end || kv.Len() != 4 {
+		t.Fatal("ExtractTo modified the source")
+	}
+
+	extracted := openTestKVAt(t, path, Options{ReadOnly: true})
+	if keys, _ := extracted.KeysPage("", 10); fmt.Sprint(keys) != "[tenant-a/1 tenant-a/2]" {
+		t.Fatalf("extracted keys = %q", keys)
+	}
+	wantValue(t, extracted, "tenant-a/1", "latest")
+	wantValue(t, extracted, "tenant-a/2", "tenant-a/2 value")
+	if extracted.DeadBytes() != 0 {
+		t.Fatalf("extracted database has %d dead bytes", extracted.DeadBytes())
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrStop can be returned from a Filter callback to stop early without
+// Filter returning an error.
+var ErrStop = errors.New("stop iteration")
+
+// Filter reads every value and calls fn for each key/value pair that pred
+// accepts. Values are read in file order rather than key order to keep disk
+// access sequential. Iteration stops at the first error returned by fn,
+// which Filter returns unless it is ErrStop. pred and fn run while the
+// database is locked, so they must not call back into it.
+func (kv *KV) Filter(pred func(key string, value []byte) bool, fn func(key string, value []byte) error) error {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+
+	keys := make([]string, 0, len(kv.pages))
+	for key, page := range kv.pages {
+		if !page.expired() {
+			keys = append(keys, key)
+		}
+	}
+	pages := kv.pages
+	sort.Slice(keys, func(i, j int) bool {
+		return pages[keys[i]].offset < pages[keys[j]].offset
+	})
+
+	for _, key := range keys {
+		value, err := kv.readValue(key, pages[key])
+		if err != nil {
+			return err
+		}
+		if !pred(key, value) {
+			continue
+		}
+		if err := fn(key, value); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
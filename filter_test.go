@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "c", "red apple")
+	mustInsert(t, kv, "a", "green apple")
+	mustInsert(t, kv, "b", "banana")
+	mustInsert(t, kv, "d", "apple pie")
+	// c is rewritten last, so it is visited last.
+	mustInsert(t, kv, "c", "red apple again")
+
+	apple := func(key string, value []byte) bool { return bytes.Contains(value, []byte("apple")) }
+	var visited []string
+	err := kv.Filter(apple, func(key string, value []byte) error {
+		visited = append(visited, fmt.Sprintf("%s=%s", key, value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(visited); got != "[a=green apple d=apple pie c=red apple again]" {
+		t.Fatalf("Filter visited %s", got)
+	}
+
+	visited = nil
+	err = kv.Filter(apple, func(key string, value []byte) error {
+		visited = append(visited, key)
+		return ErrStop
+	})
+	if err != nil || fmt.Sprint(visited) != "[a]" {
+		t.Fatalf("Filter stopped early = %v, %v", visited, err)
+	}
+
+	failed := errors.New("failed")
+	if err := kv.Filter(apple, func(string, []byte) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("Filter with failing callback: %v", err)
+	}
+}
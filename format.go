@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidFormat = errors.New("not a voila database file")
+
+// Every database file starts with a header of fileMagic followed by the
+// format version as 2 little endian bytes. Records follow the header, with
+// fixed size headers in formatVersion files and varint encoded ones in
+// varintFormatVersion files.
+const (
+	fileMagic      = "VOILA\x00\x00\x00"
+	formatVersion  = 1
+	fileHeaderSize = len(fileMagic) + 2
+)
+
+// checkFileHeader validates the header of the open database file, writing
+// one first if the file is empty, and positions lastOffset after it. The
+// version in the header decides whether record headers are varint encoded.
+func (kv *KV) checkFileHeader() error {
+	info, err := kv.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() == 0 {
+		if kv.readOnly {
+			return fmt.Errorf("%w: file is empty", ErrInvalidFormat)
+		}
+		if _, err := kv.f.WriteAt(kv.fileHeader(), 0); err != nil {
+			return err
+		}
+	} else {
+		if info.Size() < int64(fileHeaderSize) {
+			return fmt.Errorf("%w: file is too short", ErrInvalidFormat)
+		}
+		header := make([]byte, fileHeaderSize)
+		if _, err := kv.f.ReadAt(header, 0); err != nil {
+			return err
+		}
+		if string(header[:len(fileMagic)]) != fileMagic {
+			return ErrInvalidFormat
+		}
+		switch version := binary.LittleEndian.Uint16(header[len(fileMagic):]); version {
+		case formatVersion:
+			kv.varintHeaders = false
+		case varintFormatVersion:
+			kv.varintHeaders = true
+		default:
+			return fmt.Errorf("%w: unsupported format version %d", ErrInvalidFormat, version)
+		}
+	}
+
+	if kv.lastOffset < uint64(fileHeaderSize) {
+		kv.lastOffset = uint64(fileHeaderSize)
+	}
+	return nil
+}
+
+// fileHeader returns the header for a new database file whose records are
+// laid out like those of this one.
+func (kv *KV) fileHeader() []byte {
+	version := uint16(formatVersion)
+	if kv.varintHeaders {
+		version = varintFormatVersion
+	}
+	header := make([]byte, fileHeaderSize)
+	copy(header, fileMagic)
+	binary.LittleEndian.PutUint16(header[len(fileMagic):], version)
+	return header
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileHeader(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+
+	kv := openTestKVAt(t, path, Options{})
+	header, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(header) != fileHeaderSize || string(header[:8]) != "VOILA\x00\x00\x00" ||
+		binary.LittleEndian.Uint16(header[8:]) != formatVersion {
+		t.Fatalf("new file starts %q", header)
+	}
+	mustInsert(t, kv, "a", "1")
+	kv.Close()
+
+	kv = openTestKVAt(t, path, Options{})
+	wantValue(t, kv, "a", "1")
+	kv.Close()
+
+	for name, contents := range map[string]string{
+		"garbage":     "this is not a database file at all",
+		"short":       "VOILA",
+		"old version": "VOILA\x00\x00\x00\x00\x00",
+		"new version": "VOILA\x00\x00\x00\xff\x00",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewKV().ConnectWithOptions(path, Options{}); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s: Connect = %v, want ErrInvalidFormat", name, err)
+		}
+		if got, _ := os.ReadFile(path); string(got) != contents {
+			t.Errorf("%s: failed Connect changed the file", name)
+		}
+	}
+
+	empty := filepath.Join(dir, "empty")
+	os.WriteFile(empty, nil, 0o600)
+	if err := NewKV().ConnectWithOptions(empty, Options{ReadOnly: true}); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("read-only Connect of an empty file = %v", err)
+	}
+}
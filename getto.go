@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// GetTo reads the value stored under key into dst and returns its length, so
+// hot loops can reuse one buffer instead of allocating a value per Get. If dst
+// is shorter than the value, nothing is read and the error wraps
+// io.ErrShortBuffer. Values encoded with WithTransformers, or read while a
+// value migration is set, still need a buffer of their own for decoding and
+// are copied into dst afterwards.
+func (kv *KV) GetTo(key string, dst []byte) (int, error) {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return 0, ErrDBNotOpen
+	}
+	if value, ok := kv.pinnedValue(key); ok {
+		kv.metrics.countGet(true)
+		return copyValue(key, dst, value)
+	}
+
+	page, ok := kv.pages[key]
+	kv.metrics.countGet(ok)
+	if !ok || page.expired() {
+		return 0, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if page.transforms != 0 || kv.migrate != nil {
+		value, err := kv.readValue(key, page)
+		if err != nil {
+			return 0, err
+		}
+		return copyValue(key, dst, value)
+	}
+
+	if err := kv.checkGetSize(key, page); err != nil {
+		return 0, err
+	}
+	if uint64(len(dst)) < page.valueSize {
+		return 0, shortBuffer(key, page.valueSize)
+	}
+	dst = dst[:page.valueSize]
+	if _, err := kv.f.ReadAt(dst, int64(page.valueOffset())); err != nil {
+		return 0, err
+	}
+
+	if page.checksummed {
+		head := make([]byte, crcSize+page.metaSize)
+		if _, err := kv.f.ReadAt(head, int64(page.valueOffset()-page.metaSize-crcSize)); err != nil {
+			return 0, err
+		}
+		crc := crc32.Update(recordChecksum(key, head[crcSize:]), crc32.IEEETable, dst)
+		if crc != binary.LittleEndian.Uint32(head) {
+			return 0, fmt.Errorf("%w: key %s at offset %d", ErrChecksumMismatch, key, page.offset)
+		}
+	}
+	return len(dst), nil
+}
+
+func copyValue(key string, dst, value []byte) (int, error) {
+	if len(dst) < len(value) {
+		return 0, shortBuffer(key, uint64(len(value)))
+	}
+	return copy(dst, value), nil
+}
+
+func shortBuffer(key string, size uint64) error {
+	return fmt.Errorf("%w: value of key %s is %d bytes", io.ErrShortBuffer, key, size)
+}
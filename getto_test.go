@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGetTo(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"plain":       nil,
+		"transformed": {WithTransformers(xorTransformer{0x5a})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			kv := openTestKV(t, opts...)
+			mustInsert(t, kv, "a", "hello")
+			mustInsert(t, kv, "empty", "")
+
+			buf := make([]byte, 8)
+			n, err := kv.GetTo("a", buf)
+			if err != nil || string(buf[:n]) != "hello" {
+				t.Fatalf("GetTo = %q, %v", buf[:n], err)
+			}
+			if n, err := kv.GetTo("empty", buf); err != nil || n != 0 {
+				t.Fatalf("GetTo of an empty value = %d, %v", n, err)
+			}
+
+			short := []byte("abcd")
+			if _, err := kv.GetTo("a", short); !errors.Is(err, io.ErrShortBuffer) {
+				t.Fatalf("GetTo into a short buffer: %v", err)
+			}
+			if string(short) != "abcd" {
+				t.Fatalf("short buffer was written to: %q", short)
+			}
+			if _, err := kv.GetTo("missing", buf); !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("GetTo missing key: %v", err)
+			}
+
+			if err := kv.Pin("a"); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := kv.GetTo("a", buf); err != nil || string(buf[:n]) != "hello" {
+				t.Fatalf("GetTo of a pinned key = %q, %v", buf[:n], err)
+			}
+		})
+	}
+}
+
+func BenchmarkGetTo(b *testing.B) {
+	kv := openTestKV(b)
+	mustInsert(b, kv, "a", strings.Repeat("v", 4096))
+
+	b.Run("Get", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := kv.Get("a"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("GetTo", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 4096)
+		for i := 0; i < b.N; i++ {
+			if _, err := kv.GetTo("a", buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// indexPath is where Connect looks for a sidecar index for the database file
+// called name.
+func indexPath(name string) string {
+	return name + ".idx"
+}
+
+// indexVersion is bumped whenever the sidecar layout changes. The first
+// sidecars had no version and started with the database file size instead,
+// which can never be 2 or more.
+const indexVersion = 5
+
+// SaveIndex writes the in-memory index to a sidecar file at path so that a
+// later Connect can skip scanning the database. The sidecar records the size
+// and modification time of the database file and is ignored once either
+// changes. Connect only looks for it next to the database file, at the
+// database file name plus ".idx".
+//
+// The sidecar starts with its format version, the database file size,
+// modification time and number of entries, followed by one entry per key:
+//
+//	+-------------+--------+----------+-----+--------+------+----------+------------+-----------+------------+----------+---------+
+//	| Bucket Size | Bucket | Key Size | Key | Offset | Size | Key Size | Value Size | Meta Size | Transforms | Checksum | Expires |
+//	+-------------+--------+----------+-----+--------+------+----------+------------+-----------+------------+----------+---------+
+//
+// with every number stored as 8 little endian bytes. Bucket names the Bucket
+// holding the key and is empty for the default key space. Checksum is 1 for
+// records carrying a checksum and 0 otherwise. Expires is the expiry time
+// set by InsertWithTTL in Unix nanoseconds, or 0.
+func (kv *KV) SaveIndex(path string) error {
+	unlock := kv.readLock()
+	defer unlock()
+	return kv.saveIndex(path)
+}
+
+func (kv *KV) saveIndex(path string) error {
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	if kv.unreadTail {
+		// The index does not cover the records after the unreadable one,
+		// but the sidecar would be taken to describe the whole file.
+		return fmt.Errorf("%w at offset %d; not saving an index that leaves them out", ErrUnreadableRecords, kv.lastOffset)
+	}
+	info, err := kv.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := createReplacement(tmp, kv.filePerm())
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	w := bufio.NewWriter(f)
+	buf := make([]byte, 8)
+	putUint64 := func(v uint64) {
+		binary.LittleEndian.PutUint64(buf, v)
+		w.Write(buf)
+	}
+
+	putUint64(indexVersion)
+	putUint64(uint64(info.Size()))
+	putUint64(uint64(info.ModTime().UnixNano()))
+	count := len(kv.pages)
+	for _, pages := range kv.buckets {
+		count += len(pages)
+	}
+	putUint64(uint64(count))
+	putEntry := func(bucket, key string, page Page) {
+		putUint64(uint64(len(bucket)))
+		w.WriteString(bucket)
+		putUint64(uint64(len(key)))
+		w.WriteString(key)
+		putUint64(page.offset)
+		putUint64(page.size)
+		putUint64(page.keySize)
+		putUint64(page.valueSize)
+		putUint64(page.metaSize)
+		putUint64(uint64(page.transforms))
+		var checksummed uint64
+		if page.checksummed {
+			checksummed = 1
+		}
+		putUint64(checksummed)
+		putUint64(uint64(page.expires))
+	}
+	for key, page := range kv.pages {
+		putEntry("", key, page)
+	}
+	for bucket, pages := range kv.buckets {
+		for key, page := range pages {
+			putEntry(bucket, key, page)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadIndex replaces the in-memory index with the one in the sidecar at path.
+// It returns false, leaving the index untouched, if the sidecar is missing,
+// unreadable or does not describe the current database file.
+func (kv *KV) loadIndex(path string) bool {
+	info, err := kv.f.Stat()
+	if err != nil {
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	buf := make([]byte, 8)
+	readUint64 := func() uint64 {
+		if err == nil {
+			_, err = io.ReadFull(r, buf)
+		}
+		return binary.LittleEndian.Uint64(buf)
+	}
+
+	version := readUint64()
+	size := readUint64()
+	modTime := readUint64()
+	count := readUint64()
+	if err != nil || version != indexVersion || size != uint64(info.Size()) || modTime != uint64(info.ModTime().UnixNano()) {
+		return false
+	}
+
+	pages := make(map[string]Page)
+	var buckets map[string]map[string]Page
+	for i := uint64(0); i < count; i++ {
+		bucketLen := readUint64()
+		if err != nil || bucketLen > size {
+			return false
+		}
+		bucket := make([]byte, bucketLen)
+		if _, err = io.ReadFull(r, bucket); err != nil {
+			return false
+		}
+		keyLen := readUint64()
+		if err != nil || keyLen > size {
+			return false
+		}
+		key := make([]byte, keyLen)
+		if _, err = io.ReadFull(r, key); err != nil {
+			return false
+		}
+		page := Page{
+			offset:    readUint64(),
+			size:      readUint64(),
+			keySize:   readUint64(),
+			valueSize: readUint64(),
+			metaSize:  readUint64(),
+		}
+		transforms := readUint64()
+		checksummed := readUint64()
+		page.expires = int64(readUint64())
+		if err != nil || page.offset+page.size > size || transforms > 0xf || checksummed > 1 {
+			return false
+		}
+		page.transforms = uint8(transforms)
+		page.checksummed = checksummed == 1
+		if page.expired() {
+			continue
+		}
+		if bucketLen == 0 {
+			pages[string(key)] = page
+			continue
+		}
+		if buckets == nil {
+			buckets = make(map[string]map[string]Page)
+		}
+		if buckets[string(bucket)] == nil {
+			buckets[string(bucket)] = make(map[string]Page)
+		}
+		buckets[string(bucket)][string(key)] = page
+	}
+	if _, err := r.ReadByte(); !errors.Is(err, io.EOF) {
+		return false
+	}
+
+	var liveBytes uint64
+	for _, page := range pages {
+		liveBytes += page.size
+	}
+	for _, bucketPages := range buckets {
+		for _, page := range bucketPages {
+			liveBytes += page.size
+		}
+	}
+
+	kv.pages = pages
+	kv.buckets = buckets
+	kv.lastOffset = size
+	kv.deadBytes = size - uint64(fileHeaderSize) - liveBytes
+	return true
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// breakHeader zeroes the record header at offset so that a scan of the file
+// stops there. The modification time of the file is kept unless touch is set.
+func breakHeader(t *testing.T, path string, offset uint64, touch bool) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt(make([]byte, 16), int64(offset))
+	f.Close()
+	modTime := info.ModTime()
+	if touch {
+		modTime = modTime.Add(time.Second)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSidecarIndexFastPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	mustInsert(t, kv, "a", "3")
+	if err := kv.InsertWithTTL("ttl", []byte("t"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	bOffset := kv.pages["b"].offset
+	dead := kv.deadBytes
+	kv.Close()
+
+	// A scan would now stop at b, so finding every key shows the sidecar
+	// was used.
+	breakHeader(t, path, bOffset, false)
+	kv = openTestKVAt(t, path, Options{ReadOnly: true})
+	if kv.Len() != 3 {
+		t.Fatalf("loaded %d keys from the sidecar, want 3", kv.Len())
+	}
+	wantValue(t, kv, "a", "3")
+	wantValue(t, kv, "ttl", "t")
+	if kv.deadBytes != dead {
+		t.Fatalf("dead bytes from sidecar = %d, want %d", kv.deadBytes, dead)
+	}
+	if kv.pages["ttl"].expires == 0 {
+		t.Fatal("sidecar lost the expiry time")
+	}
+}
+
+func TestStaleSidecarIndexIgnored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	if err := kv.SaveIndex(indexPath(path)); err != nil {
+		t.Fatal(err)
+	}
+	sidecar, err := os.ReadFile(indexPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "b", "3")
+	mustInsert(t, kv, "c", "4")
+	bOffset := kv.pages["b"].offset
+	kv.Close()
+
+	// A sidecar for a shorter file is ignored.
+	if err := os.WriteFile(indexPath(path), sidecar, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	kv = openTestKVAt(t, path, Options{})
+	wantValue(t, kv, "b", "3")
+	wantValue(t, kv, "c", "4")
+	kv.Close()
+
+	// So is one for a file of the same size modified since.
+	breakHeader(t, path, bOffset, true)
+	kv = openTestKVAt(t, path, Options{ReadOnly: true})
+	if kv.Exists("c") {
+		t.Fatal("stale sidecar was used")
+	}
+	wantValue(t, kv, "a", "1")
+}
+
+func TestCloseSavesIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	if err := kv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(indexPath(path)); err != nil {
+		t.Fatalf("Close did not save the index: %v", err)
+	}
+	// A read-only handle leaves the sidecar alone.
+	os.Remove(indexPath(path))
+	ro := openTestKVAt(t, path, Options{ReadOnly: true})
+	ro.Close()
+	if _, err := os.Stat(indexPath(path)); err == nil {
+		t.Fatal("read-only Close saved an index")
+	}
+}
+
+// writeBenchDB writes a database of n keys with values of valueSize bytes to
+// a temporary file, closing it so the sidecar index is saved, and returns its
+// path.
+func writeBenchDB(b *testing.B, n, valueSize int) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "bench.db")
+	kv := openTestKVAt(b, path, Options{}, WithWriteBuffer(1<<20))
+	value := make([]byte, valueSize)
+	for i := 0; i < n; i++ {
+		if err := kv.Insert(fmt.Sprintf("key-%07d", i), value); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := kv.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// benchmarkConnect times opening the database at path read-only.
+func benchmarkConnect(b *testing.B, path string) {
+	for n := 0; n < b.N; n++ {
+		kv := NewKV()
+		if err := kv.ConnectWithOptions(path, Options{ReadOnly: true}); err != nil {
+			b.Fatal(err)
+		}
+		kv.Close()
+	}
+}
+
+// BenchmarkConnect contrasts opening a database of 100,000 keys from its
+// sidecar index with scanning the file.
+func BenchmarkConnect(b *testing.B) {
+	path := writeBenchDB(b, 100000, 100)
+	b.Run("sidecar", func(b *testing.B) { benchmarkConnect(b, path) })
+	if err := os.Remove(indexPath(path)); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("scan", func(b *testing.B) { benchmarkConnect(b, path) })
+}
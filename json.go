@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// ExportJSON writes every key and value to w as a single JSON object, with
+// keys in sorted order and values base64 encoded as encoding/json does for
+// byte slices. The output can be read back with ImportJSON. JSON strings
+// cannot hold arbitrary bytes, so the export fails on a key that is not valid
+// UTF-8.
+func (kv *KV) ExportJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('{')
+	first := true
+	var writeErr error
+	err := kv.Scan(func(key string, value []byte) bool {
+		if value == nil {
+			value = []byte{}
+		}
+		if !utf8.ValidString(key) {
+			// encoding/json would quietly replace the invalid bytes.
+			writeErr = fmt.Errorf("key %q is not valid UTF-8", key)
+			return false
+		}
+		k, _ := json.Marshal(key)
+		v, _ := json.Marshal(value)
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		bw.Write(k)
+		bw.WriteByte(':')
+		bw.Write(v)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	bw.WriteByte('}')
+	return bw.Flush()
+}
+
+// ImportJSON reads a JSON object in the form written by ExportJSON from r and
+// stores every key in it. The whole object is read before anything is
+// written, so malformed input imports nothing.
+func (kv *KV) ImportJSON(r io.Reader) error {
+	var entries map[string][]byte
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return fmt.Errorf("decoding JSON: %w", err)
+	}
+	for key, value := range entries {
+		if value == nil {
+			entries[key] = []byte{}
+		}
+	}
+	return kv.ApplyPatch(Patch{Set: entries})
+}
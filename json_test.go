@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	kv := openTestKV(t)
+	binary := make([]byte, 256)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+	mustInsert(t, kv, "b", string(binary))
+	mustInsert(t, kv, "a", "text")
+	mustInsert(t, kv, "empty", "")
+	mustInsert(t, kv, "quote\"d", "q")
+
+	var buf bytes.Buffer
+	if err := kv.ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, `{"a":"dGV4dA==","b":`) || !strings.HasSuffix(out, `"empty":"","quote\"d":"cQ=="}`) {
+		t.Fatalf("export = %s", out)
+	}
+
+	imported := openTestKV(t)
+	if err := imported.ImportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantSameContents(t, kv, imported)
+	if value, err := imported.Get("empty"); err != nil || value == nil || len(value) != 0 {
+		t.Fatalf("empty value = %v, %v", value, err)
+	}
+}
+
+func TestImportJSONMalformed(t *testing.T) {
+	kv := openTestKV(t)
+	for _, input := range []string{`{"a":"YQ==","b":`, `{"a":"not base64!"}`, `["a"]`} {
+		if err := kv.ImportJSON(strings.NewReader(input)); err == nil {
+			t.Fatalf("imported %s", input)
+		}
+	}
+	if kv.Len() != 0 {
+		t.Fatalf("malformed input imported %d keys", kv.Len())
+	}
+}
+
+func TestExportJSONInvalidKey(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "\xff", "1")
+	if err := kv.ExportJSON(&bytes.Buffer{}); err == nil {
+		t.Fatal("exported a key that is not valid UTF-8")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// WithComparator orders keys with compare instead of by their bytes wherever
+// keys are returned in sorted order. compare must return a negative number
+// when a sorts before b, a positive number when it sorts after and zero only
+// when a and b are the same key, and must be consistent between calls.
+func WithComparator(compare func(a, b string) int) Option {
+	return func(kv *KV) {
+		kv.compare = compare
+	}
+}
+
+func (kv *KV) compareKeys(a, b string) int {
+	if kv.compare != nil {
+		return kv.compare(a, b)
+	}
+	return strings.Compare(a, b)
+}
+
+// sortedKeys returns every key in the index in ascending order, leaving out
+// keys whose TTL has passed.
+func (kv *KV) sortedKeys() []string {
+	return kv.sortedKeysOf(kv.pages)
+}
+
+// sortedKeysOf is sortedKeys for the index of any key space.
+func (kv *KV) sortedKeysOf(pages map[string]Page) []string {
+	keys := make([]string, 0, len(pages))
+	for k, page := range pages {
+		if !page.expired() {
+			keys = append(keys, k)
+		}
+	}
+	if kv.compare == nil {
+		sort.Strings(keys)
+	} else {
+		sort.Slice(keys, func(i, j int) bool {
+			return kv.compare(keys[i], keys[j]) < 0
+		})
+	}
+	return keys
+}
+
+// Len returns the number of keys in the database.
+func (kv *KV) Len() int {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return len(kv.pages)
+}
+
+// Exists reports whether key is in the index. A key whose TTL has passed is
+// still in the index until the expiry sweep removes it or the file is loaded
+// again; use Has to leave such keys out.
+func (kv *KV) Exists(key string) bool {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	_, ok := kv.pages[key]
+	return ok
+}
+
+// Has reports whether key exists and can be read, that is, it is in the
+// index and its TTL, if any, has not passed.
+func (kv *KV) Has(key string) bool {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	page, ok := kv.pages[key]
+	return ok && !page.expired()
+}
+
+// KeysPage returns up to limit keys in sorted order that come strictly after
+// the after cursor, along with the cursor for the next page. Pass "" to start
+// from the first key; the returned cursor is "" once there are no more keys.
+// Writes reject empty keys, so "" is never a key itself. Each call sorts every
+// key in the index before slicing out the page, so paging costs a full sort
+// per page; it bounds what is handed back, not the work done.
+func (kv *KV) KeysPage(after string, limit int) ([]string, string) {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	if limit <= 0 {
+		return nil, ""
+	}
+
+	keys := kv.sortedKeys()
+	start := 0
+	if after != "" {
+		start = sort.Search(len(keys), func(i int) bool {
+			return kv.compareKeys(keys[i], after) > 0
+		})
+	}
+
+	end := start + limit
+	if end >= len(keys) {
+		return keys[start:], ""
+	}
+
+	page := keys[start:end]
+	return page, page[len(page)-1]
+}
+
+// List returns a one-level listing of the keys under prefix. Keys whose
+// remainder after prefix contains delimiter are collapsed into a common prefix
+// ending at the first delimiter, like a directory; the rest are returned as
+// keys. An empty delimiter lists every key under prefix.
+func (kv *KV) List(prefix string, delimiter string) (keys []string, commonPrefixes []string) {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, key := range kv.sortedKeys() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		rest := key[len(prefix):]
+		i := -1
+		if delimiter != "" {
+			i = strings.Index(rest, delimiter)
+		}
+		if i < 0 {
+			keys = append(keys, key)
+			continue
+		}
+
+		common := prefix + rest[:i+len(delimiter)]
+		if !seen[common] {
+			seen[common] = true
+			commonPrefixes = append(commonPrefixes, common)
+		}
+	}
+	return keys, commonPrefixes
+}
+
+// Entry describes an indexed key without reading its value from disk.
+type Entry struct {
+	Key       string
+	ValueSize uint64
+}
+
+// TopBySize returns the n keys with the largest values, largest first. Keys
+// with equal value sizes are ordered by key.
+func (kv *KV) TopBySize(n int) []Entry {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(kv.pages))
+	for key, page := range kv.pages {
+		if page.expired() {
+			continue
+		}
+		entries = append(entries, Entry{Key: key, ValueSize: page.valueSize})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].ValueSize != entries[j].ValueSize {
+			return entries[i].ValueSize > entries[j].ValueSize
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	if n < 0 {
+		n = 0
+	}
+	if n < len(entries) {
+		entries = entries[:n]
+	}
+	return entries
+}
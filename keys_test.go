@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeysPage(t *testing.T) {
+	kv := openTestKV(t)
+	want := make([]string, 25)
+	for i := range want {
+		want[i] = fmt.Sprintf("key-%02d", i)
+		mustInsert(t, kv, want[i], "v")
+	}
+
+	for _, limit := range []int{1, 3, 10, 25, 100} {
+		var got []string
+		after := ""
+		for pages := 0; ; pages++ {
+			if pages > len(want) {
+				t.Fatalf("limit %d: paging did not end", limit)
+			}
+			page, next := kv.KeysPage(after, limit)
+			if len(page) > limit {
+				t.Fatalf("limit %d: page of %d keys", limit, len(page))
+			}
+			got = append(got, page...)
+			if next == "" {
+				break
+			}
+			after = next
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("limit %d: paged %q, want %q", limit, got, want)
+		}
+	}
+
+	if page, next := kv.KeysPage("", 0); page != nil || next != "" {
+		t.Fatalf("limit 0 = %q, %q", page, next)
+	}
+	if page, next := kv.KeysPage("key-24", 5); len(page) != 0 || next != "" {
+		t.Fatalf("after last key = %q, %q", page, next)
+	}
+}
+
+func TestEmptyKeyRejected(t *testing.T) {
+	kv := openTestKV(t)
+	if err := kv.Insert("", []byte("v")); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("Insert empty key: %v", err)
+	}
+	if err := kv.ApplyPatch(Patch{Set: map[string][]byte{"": []byte("v")}}); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("ApplyPatch empty key: %v", err)
+	}
+	if _, err := kv.Reserve("", 1); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("Reserve empty key: %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	kv := openTestKV(t)
+	for _, key := range []string{"a/1", "a/2", "a/b/c", "a/b/d", "a/e/f/g", "a", "b/1", "ab"} {
+		mustInsert(t, kv, key, "v")
+	}
+
+	tests := []struct {
+		prefix, delimiter string
+		keys, common      []string
+	}{
+		{"", "/", []string{"a", "ab"}, []string{"a/", "b/"}},
+		{"a/", "/", []string{"a/1", "a/2"}, []string{"a/b/", "a/e/"}},
+		{"a/b/", "/", []string{"a/b/c", "a/b/d"}, nil},
+		{"a/e/", "/", nil, []string{"a/e/f/"}},
+		{"a", "", []string{"a", "a/1", "a/2", "a/b/c", "a/b/d", "a/e/f/g", "ab"}, nil},
+		{"missing/", "/", nil, nil},
+	}
+	for _, tt := range tests {
+		keys, common := kv.List(tt.prefix, tt.delimiter)
+		if fmt.Sprint(keys) != fmt.Sprint(tt.keys) || fmt.Sprint(common) != fmt.Sprint(tt.common) {
+			t.Errorf("List(%q, %q) = %q, %q, want %q, %q", tt.prefix, tt.delimiter, keys, common, tt.keys, tt.common)
+		}
+	}
+}
+
+func TestTopBySize(t *testing.T) {
+	kv := openTestKV(t)
+	for key, size := range map[string]int{"small": 1, "big": 100, "mid-b": 10, "mid-a": 10, "empty": 0} {
+		mustInsert(t, kv, key, strings.Repeat("x", size))
+	}
+	// Only the latest value of a key counts.
+	mustInsert(t, kv, "shrunk", strings.Repeat("x", 1000))
+	mustInsert(t, kv, "shrunk", "xx")
+
+	want := []Entry{{"big", 100}, {"mid-a", 10}, {"mid-b", 10}, {"shrunk", 2}, {"small", 1}, {"empty", 0}}
+	for _, n := range []int{-1, 0, 1, 3, 6, 10} {
+		end := n
+		if end < 0 {
+			end = 0
+		}
+		if end > len(want) {
+			end = len(want)
+		}
+		if got := kv.TopBySize(n); fmt.Sprint(got) != fmt.Sprint(want[:end]) {
+			t.Errorf("TopBySize(%d) = %v, want %v", n, got, want[:end])
+		}
+	}
+}
+
+// scanKeys returns the keys Scan visits, in order.
+func scanKeys(t *testing.T, kv *KV) []string {
+	t.Helper()
+	var keys []string
+	err := kv.Scan(func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// numeric orders keys holding decimal numbers by value.
+func numeric(a, b string) int {
+	x, _ := strconv.Atoi(a)
+	y, _ := strconv.Atoi(b)
+	if x != y {
+		return x - y
+	}
+	return strings.Compare(a, b)
+}
+
+func TestComparator(t *testing.T) {
+	byteOrder, numericOrder := openTestKV(t), openTestKV(t, WithComparator(numeric))
+	for _, kv := range []*KV{byteOrder, numericOrder} {
+		for _, key := range []string{"10", "2", "1", "100", "20"} {
+			mustInsert(t, kv, key, "v")
+		}
+	}
+
+	if got := fmt.Sprint(scanKeys(t, byteOrder)); got != "[1 10 100 2 20]" {
+		t.Fatalf("Scan in byte order = %s", got)
+	}
+	if got := fmt.Sprint(scanKeys(t, numericOrder)); got != "[1 2 10 20 100]" {
+		t.Fatalf("Scan with comparator = %s", got)
+	}
+
+	var cursor []string
+	for c := numericOrder.Cursor(); c.Next(); {
+		cursor = append(cursor, c.Key())
+	}
+	if got := fmt.Sprint(cursor); got != "[1 2 10 20 100]" {
+		t.Fatalf("Cursor with comparator = %s", got)
+	}
+	page, next := numericOrder.KeysPage("", 2)
+	if fmt.Sprint(page) != "[1 2]" {
+		t.Fatalf("first page with comparator = %q", page)
+	}
+	if page, _ = numericOrder.KeysPage(next, 2); fmt.Sprint(page) != "[10 20]" {
+		t.Fatalf("second page with comparator = %q", page)
+	}
+}
+
+func TestLen(t *testing.T) {
+	kv := openTestKV(t)
+	if kv.Len() != 0 {
+		t.Fatalf("Len of empty database = %d", kv.Len())
+	}
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	mustInsert(t, kv, "a", "3")
+	if kv.Len() != 2 {
+		t.Fatalf("Len after overwrite = %d, want 2", kv.Len())
+	}
+	if err := kv.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if kv.Len() != 1 {
+		t.Fatalf("Len after delete = %d, want 1", kv.Len())
+	}
+}
+
+func TestHas(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	if err := kv.InsertWithTTL("short", []byte("1"), 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if !kv.Has("a") || !kv.Exists("a") || !kv.Has("short") {
+		t.Fatal("live keys are missing")
+	}
+	if kv.Has("missing") || kv.Exists("missing") {
+		t.Fatal("missing key reported present")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if !kv.Exists("short") || kv.Has("short") {
+		t.Fatalf("expired key: Exists %v, Has %v", kv.Exists("short"), kv.Has("short"))
+	}
+	if err := kv.sweepExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if kv.Exists("short") || !kv.Has("a") {
+		t.Fatal("sweep did not remove only the expired key")
+	}
+}
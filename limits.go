@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEmptyKey      = errors.New("empty key")
+	ErrKeyTooLarge   = errors.New("key too large")
+	ErrValueTooLarge = errors.New("value too large")
+)
+
+// Default limits on what can be written, used unless changed with
+// WithMaxKeySize and WithMaxValueSize.
+const (
+	defaultMaxKeySize   = 64 << 10
+	defaultMaxValueSize = 1 << 30
+)
+
+// WithMaxKeySize makes writes of keys longer than limit bytes fail with
+// ErrKeyTooLarge. Loading stops at a record with a longer key, treating it as
+// corrupt.
+func WithMaxKeySize(limit uint64) Option {
+	return func(kv *KV) {
+		kv.maxKeySize = limit
+	}
+}
+
+// WithMaxValueSize makes writes of values longer than limit bytes fail with
+// ErrValueTooLarge. Loading stops at a record with a longer value, treating
+// it as corrupt.
+func WithMaxValueSize(limit uint64) Option {
+	return func(kv *KV) {
+		kv.maxValueSize = limit
+	}
+}
+
+// checkWriteSize reports whether a key and value of the given sizes are
+// within the write limits. Empty keys are never written, which leaves ""
+// free to mean "no key" to KeysPage and other cursors.
+func (kv *KV) checkWriteSize(keySize, valueSize uint64) error {
+	if keySize == 0 {
+		return ErrEmptyKey
+	}
+	if keySize > kv.maxKeySize {
+		return fmt.Errorf("%w: %d bytes, limit is %d", ErrKeyTooLarge, keySize, kv.maxKeySize)
+	}
+	if valueSize > kv.maxValueSize {
+		return fmt.Errorf("%w: %d bytes, limit is %d", ErrValueTooLarge, valueSize, kv.maxValueSize)
+	}
+	return nil
+}
+
+// WithMaxGetSize makes Get fail with ErrValueTooLarge instead of reading a
+// value longer than limit bytes into memory. The limit applies to the value
+// as stored before it is read, and again to the value decoded by
+// WithTransformers, so a small compressed record cannot expand past it on
+// its way to the caller. Large values can still be streamed with
+// ValueSection.
+func WithMaxGetSize(limit uint64) Option {
+	return func(kv *KV) {
+		kv.maxGetSize = limit
+	}
+}
+
+func (kv *KV) checkGetSize(key string, page Page) error {
+	return kv.checkDecodedSize(key, page.valueSize)
+}
+
+// checkDecodedSize is checkGetSize for a value of size bytes once decoded.
+func (kv *KV) checkDecodedSize(key string, size uint64) error {
+	if kv.maxGetSize > 0 && size > kv.maxGetSize {
+		return fmt.Errorf("%w: key %s holds %d bytes, limit is %d", ErrValueTooLarge, key, size, kv.maxGetSize)
+	}
+	return nil
+}
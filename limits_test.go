@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMaxKeyAndValueSize(t *testing.T) {
+	kv := openTestKV(t, WithMaxKeySize(4), WithMaxValueSize(8))
+	mustInsert(t, kv, "four", "eight...")
+	if err := kv.Insert("fives", []byte("v")); !errors.Is(err, ErrKeyTooLarge) {
+		t.Fatalf("insert of a long key: %v", err)
+	}
+	if err := kv.Insert("k", []byte("nine.....")); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("insert of a long value: %v", err)
+	}
+	if kv.Len() != 1 {
+		t.Fatalf("Len = %d after rejected inserts", kv.Len())
+	}
+}
+
+func TestLoadRejectsOversizedRecords(t *testing.T) {
+	path := t.TempDir() + "/db"
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, strings.Repeat("k", 20), "2")
+	mustInsert(t, kv, "b", strings.Repeat("v", 50))
+	kv.Close()
+	// Without the sidecar saved on Close, the records are scanned.
+	os.Remove(indexPath(path))
+
+	for _, tc := range []struct {
+		name string
+		opt  Option
+		want error
+		keys int
+	}{
+		{"key", WithMaxKeySize(10), ErrKeyTooLarge, 1},
+		{"value", WithMaxValueSize(10), ErrValueTooLarge, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kv := openTestKVAt(t, path, Options{ReadOnly: true}, tc.opt)
+			if kv.Len() != tc.keys {
+				t.Fatalf("loaded %d keys, want the %d before the oversized record", kv.Len(), tc.keys)
+			}
+
+			strict := NewKV(tc.opt, WithStrictLoad())
+			err := strict.ConnectWithOptions(path, Options{ReadOnly: true})
+			strict.Close()
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("strict load: %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestMaxGetSize(t *testing.T) {
+	kv := openTestKV(t, WithMaxGetSize(10))
+	big := strings.Repeat("x", 11)
+	mustInsert(t, kv, "big", big)
+	mustInsert(t, kv, "small", "0123456789")
+
+	wantValue(t, kv, "small", "0123456789")
+	if _, err := kv.Get("big"); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("Get over the limit: %v", err)
+	}
+	if _, err := kv.GetTo("big", make([]byte, 100)); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("GetTo over the limit: %v", err)
+	}
+
+	// Streaming reads do not hold the whole value in memory.
+	r, err := kv.GetReader("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || string(got) != big {
+		t.Fatalf("GetReader over the limit = %q, %v", got, err)
+	}
+	section, err := kv.ValueSection("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if section.Size() != int64(len(big)) {
+		t.Fatalf("ValueSection size = %d", section.Size())
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// corruptValue flips the first value byte of key's record on disk.
+func corruptValue(t *testing.T, kv *KV, key string) {
+	t.Helper()
+	if err := kv.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	page := kv.pages[key]
+	b := make([]byte, 1)
+	kv.f.ReadAt(b, int64(page.valueOffset()))
+	b[0] ^= 0xff
+	if _, err := kv.f.WriteAt(b, int64(page.valueOffset())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPartialTrailingRecordDropped(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	kv.Flush()
+	end := kv.lastOffset
+	mustInsert(t, kv, "b", "2")
+	kv.Close()
+
+	// Cut the last record in half, as a crash during the write would.
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(indexPath(path))
+
+	kv = openTestKVAt(t, path, Options{})
+	wantValue(t, kv, "a", "1")
+	if kv.Exists("b") {
+		t.Fatal("partial record was loaded")
+	}
+	if info, _ := os.Stat(path); uint64(info.Size()) != end {
+		t.Fatalf("file is %d bytes, want %d", info.Size(), end)
+	}
+	mustInsert(t, kv, "c", "3")
+	if err := kv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCorruptSizeMidFileKept(t *testing.T) {
+	// Each flip makes the key size of b run past the end of the file while
+	// staying under the key size limit, as a half-written record's would.
+	for name, tc := range map[string]struct {
+		opts []Option
+		at   uint64
+		mask byte
+	}{
+		"fixed":  {nil, 1, 0x04},
+		"varint": {[]Option{WithVarintHeaders()}, 0, 0x40},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := t.TempDir() + "/test.db"
+			kv := openTestKVAt(t, path, Options{}, tc.opts...)
+			for _, key := range []string{"a", "b", "c", "d", "e"} {
+				mustInsert(t, kv, key, key+"-value")
+			}
+			bad := kv.pages["b"].offset
+			size := fileSize(t, kv)
+			kv.Close()
+			os.Remove(indexPath(path))
+
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := make([]byte, 1)
+			f.ReadAt(b, int64(bad+tc.at))
+			b[0] ^= tc.mask
+			f.WriteAt(b, int64(bad+tc.at))
+			f.Close()
+
+			kv = openTestKVAt(t, path, Options{})
+			if kv.Len() != 1 {
+				t.Fatalf("loaded %d keys, want 1", kv.Len())
+			}
+			if fileSize(t, kv) != size {
+				t.Fatalf("file is %d bytes after Connect, want %d", fileSize(t, kv), size)
+			}
+			if err := kv.Insert("z", []byte("z")); !errors.Is(err, ErrUnreadableRecords) {
+				t.Fatalf("Insert after a damaged header: %v", err)
+			}
+		})
+	}
+}
+
+func TestWritesRefusedAfterUnreadableRecord(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	kv := openTestKVAt(t, path, Options{})
+	for _, key := range []string{"a", "b", "c", "d"} {
+		mustInsert(t, kv, key, key+"-value")
+	}
+	kv.Flush()
+	bad := kv.pages["b"].offset
+	corruptValue(t, kv, "b")
+	kv.Close()
+	os.Remove(indexPath(path))
+
+	kv = openTestKVAt(t, path, Options{ChecksumOnLoad: true})
+	if kv.Len() != 1 {
+		t.Fatalf("loaded %d keys, want 1", kv.Len())
+	}
+	if err := kv.Insert("e", []byte("e")); !errors.Is(err, ErrUnreadableRecords) {
+		t.Fatalf("Insert after stopped load: %v", err)
+	}
+	if err := kv.Delete("a"); !errors.Is(err, ErrUnreadableRecords) {
+		t.Fatalf("Delete after stopped load: %v", err)
+	}
+	if err := kv.Compact(); !errors.Is(err, ErrUnreadableRecords) {
+		t.Fatalf("Compact after stopped load: %v", err)
+	}
+
+	// The records after the bad one are still there for recovery.
+	kv.Close()
+	kv = openTestKVAt(t, path, Options{})
+	wantValue(t, kv, "d", "d-value")
+	kv.Close()
+
+	kv = openTestKVAt(t, path, Options{ChecksumOnLoad: true})
+	if err := kv.TruncateTo(bad); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "e", "e-value")
+	wantValue(t, kv, "a", "a-value")
+	wantValue(t, kv, "e", "e-value")
+}
+
+func TestZeroedTailIsWrittenOver(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	kv.Close()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(make([]byte, 100))
+	f.Close()
+
+	kv = openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "b", "2")
+	wantValue(t, kv, "a", "1")
+	wantValue(t, kv, "b", "2")
+}
+
+func TestStrictLoad(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	kv := openTestKVAt(t, path, Options{}, WithStrictLoad())
+	for _, key := range []string{"a", "b", "c"} {
+		mustInsert(t, kv, key, key+"-value")
+	}
+	bad := kv.pages["b"].offset
+	kv.Close()
+	// A clean file loads under strict load.
+	kv = openTestKVAt(t, path, Options{}, WithStrictLoad())
+	kv.Close()
+
+	// Give b a key size running past the end of the file.
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0xff, 0xff, 0xff}, int64(bad))
+	f.Close()
+	os.Remove(indexPath(path))
+
+	err = NewKV(WithStrictLoad()).ConnectWithOptions(path, Options{})
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("offset %d", bad)) {
+		t.Fatalf("strict load of corrupt file: %v", err)
+	}
+
+	// Without strict load the records before the corrupt one are salvaged.
+	kv = openTestKVAt(t, path, Options{ReadOnly: true})
+	wantValue(t, kv, "a", "a-value")
+	if kv.Len() != 1 {
+		t.Fatalf("loaded %d keys, want 1", kv.Len())
+	}
+}
+
+func TestLoadFromStorage(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	kv := openTestKVAt(t, path, Options{})
+	want := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key-%02d", i)
+		want[key] = strings.Repeat("v", i*100)
+		mustInsert(t, kv, key, want[key])
+	}
+	if err := kv.InsertWithMeta("m", []byte("with meta"), map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	want["m"] = "with meta"
+	for i := 0; i < 50; i += 5 {
+		key := fmt.Sprintf("key-%02d", i)
+		if err := kv.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+		delete(want, key)
+	}
+	dead := kv.DeadBytes()
+	kv.Close()
+	os.Remove(indexPath(path))
+
+	kv = openTestKVAt(t, path, Options{})
+	if kv.Len() != len(want) {
+		t.Fatalf("loaded %d keys, want %d", kv.Len(), len(want))
+	}
+	for key, value := range want {
+		wantValue(t, kv, key, value)
+	}
+	if kv.DeadBytes() != dead {
+		t.Fatalf("loaded %d dead bytes, want %d", kv.DeadBytes(), dead)
+	}
+	if err := kv.ValidateIndex(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkLoad scans files of 1,000 keys with small and large values. Values
+// are skipped rather than read, so the large values should barely slow the
+// scan down.
+func BenchmarkLoad(b *testing.B) {
+	for _, size := range []int{100, 16 << 10} {
+		path := writeBenchDB(b, 1000, size)
+		if err := os.Remove(indexPath(path)); err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("value=%d", size), func(b *testing.B) { benchmarkConnect(b, path) })
+	}
+}
+
+func TestLoadStopsAtBadHeaders(t *testing.T) {
+	dir := t.TempDir()
+	good := dir + "/good.db"
+	kv := openTestKVAt(t, good, Options{})
+	mustInsert(t, kv, "a", "1")
+	kv.Close()
+	prefix, err := os.ReadFile(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tails := map[string][]byte{
+		"zeroed header":  make([]byte, 64),
+		"zero key size":  append(fixedHeader(0, 0), make([]byte, 32)...),
+		"huge key size":  append(fixedHeader(1<<40, 0), make([]byte, 32)...),
+		"huge data size": append(fixedHeader(1, 1<<40), make([]byte, 32)...),
+	}
+	for name, tail := range tails {
+		path := dir + "/" + strings.ReplaceAll(name, " ", "-") + ".db"
+		if err := os.WriteFile(path, append(append([]byte(nil), prefix...), tail...), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan *KV, 1)
+		go func() {
+			kv := NewKV()
+			if err := kv.ConnectWithOptions(path, Options{ReadOnly: true}); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+			done <- kv
+		}()
+		select {
+		case kv := <-done:
+			if kv.Len() != 1 {
+				t.Errorf("%s: loaded %d keys, want 1", name, kv.Len())
+			}
+			kv.Close()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: load did not finish", name)
+		}
+	}
+}
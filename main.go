@@ -1,104 +1,704 @@
 package main
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"log"
 	"os"
+	"sync"
 )
 
-// Page represents the layout of data on disk.
-// A Page on disk contains the following in order: key size, value size, value and data.
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	ErrDBNotOpen   = errors.New("database not opened")
+	ErrReadOnly    = errors.New("database is read-only")
+	// ErrUnreadableRecords is returned by writes after loading stopped at a
+	// record it could not read with data still following it. New records
+	// would overwrite that data, so writing is refused until the file is cut
+	// back with TruncateTo or ConnectAt.
+	ErrUnreadableRecords = errors.New("database file has unreadable records")
+)
+
+// Page describes where a record sits in the database file. Only keys are kept
+// in memory, so the value itself is not part of a Page. A record is laid out
+// as follows, with the sections marked * present only when their flag is set:
+//
+//	+----------+------------+----------+---------+-------------+----------+
+//	|         Header        |                     Data                    |
+//	+----------+------------+----------+---------+-------------+----------+
+//	| 8 bytes  | 8 bytes    | key size | 4 bytes | 8 + n bytes | the rest |
+//	+----------+------------+----------+---------+-------------+----------+
+//	| Key Size | Value Size | Key      | CRC32*  | Metadata*   | Value    |
+//	+----------+------------+----------+---------+-------------+----------+
+//
+// Value Size counts everything after the key, so a record can be skipped using
+// its header alone. Its top eight bits are flags:
 //
-//	+----------+------------+-----------+-----------+
-//	|        Header         |         Data          |
-//	+----------+------------+-----------+-----------+
-//	| 8 bytes  | 8 bytes    | *64 bytes | *64 bytes |
-//	+----------+------------+-----------+-----------+
-//	| Key Size | Value Size | Key       | Value     |
-//	+----------+------------+-----------+-----------+
+//	bit 63      metaFlag       metadata block present (InsertWithMeta, Bucket)
+//	bit 62      reservedFlag   unfinished Reserve, skipped on load
+//	bits 58-61  transforms     WithTransformers stages applied to the value
+//	bit 57      tombstoneFlag  written by Delete, removes the key on load
+//	bit 56      checksumFlag   CRC32 of key, metadata and value present
 //
-// Because we only store keys in memory, the value is omitted from the Page struct.
+// Files created with WithVarintHeaders store the same header in fewer bytes;
+// see varintFormatVersion.
 type Page struct {
-	keySize   uint64
-	valueSize uint64
-	offset    uint64
-	size      uint64
+	keySize     uint64
+	valueSize   uint64
+	metaSize    uint64
+	offset      uint64
+	size        uint64
+	transforms  uint8
+	checksummed bool
+	// expires is the Unix time in nanoseconds after which the key reads as
+	// missing, or 0 if it never expires. See InsertWithTTL.
+	expires int64
+}
+
+const (
+	metaFlag       uint64 = 1 << 63
+	reservedFlag   uint64 = 1 << 62
+	transformShift        = 58
+	tombstoneFlag  uint64 = 1 << 57
+	checksumFlag   uint64 = 1 << 56
+	sizeMask              = checksumFlag - 1
+)
+
+// valueOffset returns the file offset of the first byte of the value. The
+// value is always the last section of a record.
+func (p Page) valueOffset() uint64 {
+	return p.offset + p.size - p.valueSize
 }
 
+// KV is safe for concurrent use. Writes hold mu exclusively and reads share
+// it, so no method may call another exported method while holding it.
 type KV struct {
+	mu sync.RWMutex
+
 	pages      map[string]Page
 	f          *os.File
 	lastOffset uint64
+
+	// buckets indexes the keys of each named Bucket, which are kept out of
+	// pages.
+	buckets map[string]map[string]Page
+
+	staleGuard   bool
+	staleCatchUp bool
+
+	opLog   io.Writer
+	migrate MigrationFunc
+
+	strictChecks   bool
+	strictLoad     bool
+	checksumOnLoad bool
+
+	writeLimiter   *rateLimiter
+	writeCallbacks []WriteCallback
+
+	writeBufferSize int
+	pending         []byte
+	syncWrites      bool
+
+	readOnly      bool
+	varintHeaders bool
+	// unreadTail is set when loading stopped before the end of the file
+	// with something other than a partial record or zeroes left after it.
+	unreadTail bool
+
+	maxGetSize   uint64
+	maxKeySize   uint64
+	maxValueSize uint64
+	quotas       map[string]uint64
+
+	pinned map[string][]byte
+	cache  *valueCache
+
+	// reservations holds the ValueWriters from Reserve that are neither
+	// closed nor aborted. Their records sit at fixed offsets in the file,
+	// so it must not be rewritten or cut short while any are open.
+	reservations map[*ValueWriter]struct{}
+
+	// exports counts the ExportConsistent calls streaming from f without
+	// holding the lock; exportsDone is signalled as each one ends.
+	exports     int
+	exportsDone *sync.Cond
+
+	transformers []Transformer
+
+	sweepStop chan struct{}
+	sweepDone chan struct{}
+
+	skipMalformedCSV bool
+
+	compare func(a, b string) int
+
+	metrics metrics
+
+	deadBytes uint64
 }
 
-func NewKV() *KV {
-	return &KV{pages: make(map[string]Page)}
+func NewKV(opts ...Option) *KV {
+	kv := &KV{
+		pages:        make(map[string]Page),
+		maxKeySize:   defaultMaxKeySize,
+		maxValueSize: defaultMaxValueSize,
+	}
+	for _, opt := range opts {
+		opt(kv)
+	}
+	return kv
 }
 
-func (kv *KV) Connect() *os.File {
-	f, err := os.OpenFile("db.db", os.O_CREATE|os.O_RDWR, os.ModePerm)
+// readLock takes the lock for a method that reads the file. That is normally
+// the shared read lock, but with WithValueMigration a read may write the
+// migrated value back, and buffered records have to be flushed before the
+// file is read, so in those cases the write lock is taken instead. A failed
+// flush leaves the records buffered for Flush or Close to report.
+func (kv *KV) readLock() (unlock func()) {
+	if kv.migrate == nil {
+		kv.mu.RLock()
+		if len(kv.pending) == 0 {
+			return kv.mu.RUnlock
+		}
+		kv.mu.RUnlock()
+	}
+	kv.mu.Lock()
+	kv.flush()
+	return kv.mu.Unlock
+}
 
-	if err != nil {
-		log.Println("could not open database file for writing")
+// Connect opens db.db in the working directory with the default Options,
+// exiting the program if it cannot be opened.
+func (kv *KV) Connect() *os.File {
+	if err := kv.ConnectWithOptions("db.db", Options{}); err != nil {
+		log.Println("could not connect to database")
 		log.Fatal(err)
 	}
 
 	log.Println("database connected")
+	return kv.f
+}
+
+// ConnectWithOptions opens the database file called filename, creating it
+// unless opts.ReadOnly is set, and loads its index.
+func (kv *KV) ConnectWithOptions(filename string, opts Options) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	flag := os.O_CREATE | os.O_RDWR
+	if opts.ReadOnly {
+		flag = os.O_RDONLY
+	}
+	perm := opts.Perm
+	if perm == 0 {
+		perm = os.ModePerm
+	}
+	kv.syncWrites = kv.syncWrites || opts.SyncOnWrite
+	kv.checksumOnLoad = kv.checksumOnLoad || opts.ChecksumOnLoad
+	kv.varintHeaders = kv.varintHeaders || opts.VarintHeaders
+	if opts.MaxKeySize > 0 {
+		kv.maxKeySize = opts.MaxKeySize
+	}
+	if opts.MaxValueSize > 0 {
+		kv.maxValueSize = opts.MaxValueSize
+	}
+	if opts.CacheEntries > 0 || opts.CacheBytes > 0 {
+		kv.cache = newValueCache(opts.CacheEntries, opts.CacheBytes)
+	}
+
+	f, err := os.OpenFile(filename, flag, perm)
+	if err != nil {
+		return err
+	}
+
 	kv.f = f
-	return f
+	kv.readOnly = opts.ReadOnly
+	kv.resetIndex()
+	err = kv.checkFileHeader()
+	if err == nil && !kv.loadIndex(indexPath(f.Name())) {
+		err = kv.loadFromStorage()
+		if err == nil {
+			err = kv.dropPartialRecord()
+		}
+		if err == nil {
+			err = kv.checkUnreadTail()
+		}
+		if err != nil {
+			err = fmt.Errorf("loading database file: %w", err)
+		}
+	}
+	if err == nil {
+		// Keys pinned before an earlier Close may have changed or gone.
+		err = kv.refreshPins()
+	}
+	if err != nil {
+		f.Close()
+		kv.f = nil
+		return err
+	}
+	return nil
 }
 
-func (kv *KV) loadFromStorage() {
-	var keySize uint64
-	var valueSize uint64
-	var offset int64
+// Close stops any expiry sweep, writes out any buffered records and closes
+// the database file. With WithStrictChecks enabled the file is verified first
+// and any inconsistency is returned after closing. Unless the database is
+// read-only or has unreadable records, the index is saved to the sidecar next
+// to the file (see SaveIndex) so the next Connect does not have to scan the
+// file. Once closed, operations fail with ErrDBNotOpen and further calls to
+// Close do nothing.
+func (kv *KV) Close() error {
+	kv.StopExpirySweep()
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.waitForExports()
+	if kv.f == nil {
+		return nil
+	}
+
+	flushErr := kv.flush()
+	var verifyErr error
+	if kv.strictChecks && flushErr == nil {
+		verifyErr = kv.verify()
+	}
+	var indexErr error
+	if !kv.readOnly && !kv.unreadTail && flushErr == nil && verifyErr == nil {
+		indexErr = kv.saveIndex(indexPath(kv.f.Name()))
+	}
+	err := kv.f.Close()
+	kv.f = nil
+	kv.cache.clear()
+	kv.reservations = nil
+	if err != nil {
+		return err
+	}
+	return errors.Join(flushErr, verifyErr, indexErr)
+}
+
+// resetIndex forgets the index and write position of the file used before,
+// so that a KV connected again, to the same file or another, starts from
+// what is loaded from the new one.
+func (kv *KV) resetIndex() {
+	kv.pages = make(map[string]Page)
+	kv.buckets = nil
+	kv.cache.clear()
+	kv.lastOffset = 0
+	kv.deadBytes = 0
+	kv.pending = nil
+	kv.unreadTail = false
+}
+
+// loadFromStorage indexes every record from lastOffset to the end of the file.
+// It stops at the first record it cannot read; with WithStrictLoad that is an
+// error unless the file ends cleanly on a record boundary.
+func (kv *KV) loadFromStorage() error {
+	offset := int64(kv.lastOffset)
+
+	// Values are never read while loading, so records are checked against
+	// the file size to catch one cut short.
+	info, err := kv.f.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := info.Size()
 
 	for {
 		page := Page{}
-		keySizeBuf := make([]byte, 8)
-		valueSizeBuf := make([]byte, 8)
+		start := offset
+		if offset >= fileSize {
+			return nil
+		}
 
-		n, err := kv.f.ReadAt(keySizeBuf, offset)
+		keySize, valueSize, flags, headerSize, err := kv.readPageHeader(offset)
 		if err != nil {
-			return
+			return kv.loadFailed(start, err)
 		}
-		offset += int64(n)
-		keySize = binary.LittleEndian.Uint64(keySizeBuf)
+		offset += int64(headerSize)
+		if keySize == 0 && valueSize == 0 && flags == 0 {
+			// Every record written since checksums were added has at least
+			// the checksum flag set, so this is zeroed space rather than a
+			// record.
+			return kv.loadFailed(start, errors.New("zeroed record header"))
+		}
+		hasMeta := flags&metaFlag != 0
+		reserved := flags&reservedFlag != 0
+		tombstone := flags&tombstoneFlag != 0
+		page.checksummed = flags&checksumFlag != 0
+		page.transforms = uint8(flags >> transformShift & 0xf)
 
-		n, err = kv.f.ReadAt(valueSizeBuf, offset)
-		if err != nil {
-			return
+		remaining := uint64(fileSize - offset)
+		if keySize > remaining || valueSize > remaining-keySize {
+			return kv.loadFailed(start, io.ErrUnexpectedEOF)
+		}
+		if keySize > kv.maxKeySize {
+			return kv.loadFailed(start, ErrKeyTooLarge)
+		}
+
+		if reserved {
+			// An abandoned Reserve; skip the region as slack.
+			offset += int64(keySize + valueSize)
+			kv.deadBytes += uint64(offset - start)
+			kv.lastOffset = uint64(offset)
+			continue
 		}
-		offset += int64(n)
-		valueSize = binary.LittleEndian.Uint64(valueSizeBuf)
 
 		keyBuf := make([]byte, keySize)
-		valueBuf := make([]byte, valueSize)
-		n, err = kv.f.ReadAt(keyBuf, offset)
-		if err != nil {
-			return
+		if _, err := kv.f.ReadAt(keyBuf, offset); err != nil {
+			return kv.loadFailed(start, err)
 		}
-		offset += int64(n)
+		offset += int64(keySize)
 		key := string(keyBuf)
 
-		n, err = kv.f.ReadAt(valueBuf, offset)
-		if err != nil {
-			return
+		if page.checksummed {
+			if valueSize < crcSize {
+				return kv.loadFailed(start, errors.New("checksum overruns record"))
+			}
+			if kv.checksumOnLoad {
+				data := make([]byte, valueSize)
+				if _, err := kv.f.ReadAt(data, offset); err != nil {
+					return kv.loadFailed(start, err)
+				}
+				if recordChecksum(key, data[crcSize:]) != binary.LittleEndian.Uint32(data) {
+					return kv.loadFailed(start, ErrChecksumMismatch)
+				}
+			}
+			valueSize -= crcSize
+			offset += crcSize
 		}
-		offset += int64(n)
-		value := string(valueBuf)
-		log.Println(value)
+
+		bucket := ""
+		if hasMeta {
+			metaSizeBuf := make([]byte, 8)
+			if _, err := kv.f.ReadAt(metaSizeBuf, offset); err != nil {
+				return kv.loadFailed(start, err)
+			}
+			page.metaSize = binary.LittleEndian.Uint64(metaSizeBuf) + 8
+			if page.metaSize > valueSize {
+				return kv.loadFailed(start, errors.New("metadata block overruns record"))
+			}
+			block := make([]byte, page.metaSize)
+			if _, err := kv.f.ReadAt(block, offset); err != nil {
+				return kv.loadFailed(start, err)
+			}
+			page.expires = metaExpiry(block)
+			bucket = metaBucket(block)
+			valueSize -= page.metaSize
+			offset += int64(page.metaSize)
+		}
+		pages := kv.bucketPages(bucket, !tombstone)
+
+		if tombstone {
+			offset += int64(valueSize)
+			kv.retireIn(pages, key)
+			delete(pages, key)
+			kv.deadBytes += uint64(offset - start)
+			kv.lastOffset = uint64(offset)
+			continue
+		}
+
+		if page.transforms == 0 && valueSize > kv.maxValueSize {
+			return kv.loadFailed(start, ErrValueTooLarge)
+		}
+		offset += int64(valueSize)
 		page.keySize = keySize
 		page.valueSize = valueSize
-		page.size = keySize + valueSize + 8 + 8
-		page.offset = uint64(offset) - page.size
-		kv.pages[key] = page
+		page.size = uint64(offset - start)
+		page.offset = uint64(start)
+		kv.retireIn(pages, key)
+		if page.expired() {
+			delete(pages, key)
+			kv.deadBytes += page.size
+		} else {
+			pages[key] = page
+		}
+		kv.lastOffset = uint64(offset)
 	}
 }
 
+// loadFailed decides what an unreadable record at offset means for the load.
+// By default the scan just stops there, leaving the records before it loaded.
+func (kv *KV) loadFailed(offset int64, err error) error {
+	if !kv.strictLoad {
+		return nil
+	}
+	return fmt.Errorf("corrupt record at offset %d: %w", offset, err)
+}
+
+// dropPartialRecord cuts off a record left incomplete at the end of the file
+// by a crash part way through a write, so that new records are appended
+// right after the last complete one. A crash only ever leaves the start of
+// the last record, so the tail is cut off only if it reads as such: a header
+// with a plausible key size whose record runs past the end of the file, or
+// too little of a header to decode, and no complete record anywhere after
+// it. Anything else may be a damaged header with good records behind it and
+// is left alone; checkUnreadTail then keeps writes from overwriting it.
+func (kv *KV) dropPartialRecord() error {
+	if kv.readOnly {
+		return nil
+	}
+	info, err := kv.f.Stat()
+	if err != nil {
+		return err
+	}
+	size := uint64(info.Size())
+	if size <= kv.lastOffset {
+		return nil
+	}
+
+	remaining := size - kv.lastOffset
+	keySize, dataSize, _, headerSize, err := kv.readPageHeader(int64(kv.lastOffset))
+	if err == nil {
+		if headerSize <= remaining && keySize <= remaining-headerSize &&
+			dataSize <= remaining-headerSize-keySize {
+			return nil
+		}
+		if keySize == 0 || keySize > kv.maxKeySize {
+			return nil
+		}
+	}
+	found, err := kv.completeRecordAfter(kv.lastOffset, size)
+	if err != nil || found {
+		return err
+	}
+
+	log.Printf("dropping partial record of %d bytes at offset %d", remaining, kv.lastOffset)
+	return kv.f.Truncate(int64(kv.lastOffset))
+}
+
+// completeRecordAfter reports whether a complete record starts anywhere after
+// offset and before size: a checksummed record whose checksum matches, or a
+// reserved region, that fits in the file. The tail is read once in chunks and
+// only headers that fit are checked further, so this stays cheap even when a
+// large value was cut short.
+func (kv *KV) completeRecordAfter(offset, size uint64) (bool, error) {
+	const window = 64 * 1024
+	buf := make([]byte, window+2*binary.MaxVarintLen64)
+	for start := offset + 1; start < size; start += window {
+		n, err := kv.f.ReadAt(buf, int64(start))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, err
+		}
+		for i := 0; i < n && i < window; i++ {
+			at := start + uint64(i)
+			keySize, dataSize, flags, headerSize, err := kv.decodePageHeader(buf[i:n])
+			if err != nil || flags&checksumFlag == 0 || keySize > kv.maxKeySize || dataSize < crcSize {
+				continue
+			}
+			remaining := size - at
+			if headerSize > remaining || keySize > remaining-headerSize ||
+				dataSize > remaining-headerSize-keySize {
+				continue
+			}
+			if flags&reservedFlag != 0 {
+				return true, nil
+			}
+			ok, err := kv.checksumMatches(int64(at+headerSize), keySize, dataSize)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// checksumMatches reports whether the checksummed record whose key starts at
+// offset holds the checksum of its contents. The data is streamed rather than
+// read into memory, since its size comes from a header that may be garbage.
+func (kv *KV) checksumMatches(offset int64, keySize, dataSize uint64) (bool, error) {
+	head := make([]byte, keySize+crcSize)
+	if _, err := kv.f.ReadAt(head, offset); err != nil {
+		return false, err
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(head[:keySize])
+	rest := io.NewSectionReader(kv.f, offset+int64(keySize+crcSize), int64(dataSize-crcSize))
+	if _, err := io.Copy(crc, rest); err != nil {
+		return false, err
+	}
+	return crc.Sum32() == binary.LittleEndian.Uint32(head[keySize:]), nil
+}
+
+// checkUnreadTail sets unreadTail if the load stopped short of the end of
+// the file and anything but zeroes follows. Zeroed space, as left by a crash
+// after the file was extended, is safe to write over.
+func (kv *KV) checkUnreadTail() error {
+	kv.unreadTail = false
+	info, err := kv.f.Stat()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 64*1024)
+	for offset := int64(kv.lastOffset); offset < info.Size(); {
+		n, err := kv.f.ReadAt(buf, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		for _, b := range buf[:n] {
+			if b != 0 {
+				log.Printf("unreadable record at offset %d; refusing writes until it is cut off", kv.lastOffset)
+				kv.unreadTail = true
+				return nil
+			}
+		}
+		offset += int64(n)
+	}
+	return nil
+}
+
+// checkWritable returns the reason the file may not be written to, if any.
+func (kv *KV) checkWritable() error {
+	if kv.readOnly {
+		return ErrReadOnly
+	}
+	if kv.unreadTail {
+		return fmt.Errorf("%w at offset %d; cut them off with TruncateTo or ConnectAt before writing", ErrUnreadableRecords, kv.lastOffset)
+	}
+	return nil
+}
+
+// readPageHeader reads the header of the record starting at offset. dataSize
+// is the length of everything that follows the key, so the record ends at
+// offset + headerSize + keySize + dataSize.
+func (kv *KV) readPageHeader(offset int64) (keySize, dataSize, flags, headerSize uint64, err error) {
+	if kv.varintHeaders {
+		return kv.readVarintHeader(offset)
+	}
+	header := make([]byte, 16)
+	if _, err = kv.f.ReadAt(header, offset); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	return kv.decodePageHeader(header)
+}
+
+// decodePageHeader decodes a record header in the format of the open file
+// from the start of buf, which may run on past the header.
+func (kv *KV) decodePageHeader(buf []byte) (keySize, dataSize, flags, headerSize uint64, err error) {
+	if kv.varintHeaders {
+		return decodeVarintHeader(buf)
+	}
+	if len(buf) < 16 {
+		return 0, 0, 0, 0, io.ErrUnexpectedEOF
+	}
+	keySize = binary.LittleEndian.Uint64(buf[:8])
+	valueSize := binary.LittleEndian.Uint64(buf[8:16])
+	return keySize, valueSize & sizeMask, valueSize &^ sizeMask, 16, nil
+}
+
 func (kv *KV) Insert(key string, value []byte) error {
-	pageBuffer := make([]byte, 0)
+	return kv.InsertContext(context.Background(), key, value)
+}
+
+// insert appends a record for key. meta is the already encoded metadata block
+// including its length prefix, or nil when the record carries no metadata.
+// It does not wait for WithWriteRateLimit, which must never happen under the
+// lock; exported methods call throttle before locking instead.
+func (kv *KV) insert(key string, meta []byte, value []byte) error {
+	stored, transforms, err := kv.encodeValue(value)
+	if err != nil {
+		return err
+	}
+	return kv.insertEncoded(key, meta, value, stored, transforms)
+}
+
+// insertThrottled is insert for exported methods that do not already hold
+// the lock. The value is encoded and the rate limit waited for before the
+// lock is taken, so a throttled writer does not hold up readers. It gives up
+// with ctx.Err() if ctx is done before anything is written.
+func (kv *KV) insertThrottled(ctx context.Context, key string, meta []byte, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stored, transforms, err := kv.encodeValue(value)
+	if err != nil {
+		return err
+	}
+	if err := kv.throttle(ctx, recordSize(key, meta, stored)); err != nil {
+		return err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return kv.insertEncoded(key, meta, value, stored, transforms)
+}
+
+// insertEncoded appends the record of value, already encoded by the
+// transformers into stored.
+func (kv *KV) insertEncoded(key string, meta, value, stored []byte, transforms uint8) error {
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	if err := kv.checkWritable(); err != nil {
+		return err
+	}
+	if err := kv.checkWriteSize(uint64(len(key)), uint64(len(value))); err != nil {
+		return err
+	}
+	if kv.staleGuard {
+		if err := kv.checkStale(); err != nil {
+			return err
+		}
+	}
+
+	bucket := metaBucket(meta)
+	if len(kv.quotas) > 0 && bucket == "" {
+		if err := kv.checkQuota(map[string]uint64{key: uint64(len(stored))}); err != nil {
+			return err
+		}
+	}
+
+	record := encodePage(key, transforms, meta, stored)
+	pageBuffer := kv.storedRecord(record)
+
+	if err := kv.appendRecords(pageBuffer); err != nil {
+		return err
+	}
+	page := Page{
+		offset:      kv.lastOffset,
+		size:        uint64(len(pageBuffer)),
+		valueSize:   uint64(len(stored)),
+		metaSize:    uint64(len(meta)),
+		keySize:     uint64(len(key)),
+		transforms:  transforms,
+		checksummed: true,
+		expires:     metaExpiry(meta),
+	}
+	pages := kv.bucketPages(bucket, true)
+	kv.retireIn(pages, key)
+	pages[key] = page
+	kv.lastOffset += uint64(len(pageBuffer))
+	kv.metrics.countInsert(1, len(pageBuffer))
+	if err := kv.syncWrite(); err != nil {
+		return err
+	}
+	if bucket == "" {
+		kv.notifyWrite(OpInsert, key, value)
+	}
+
+	return kv.recordOp(OpInsert, record)
+}
+
+// recordSize is the length of the record encodePage lays out.
+func recordSize(key string, meta, value []byte) int {
+	return 16 + len(key) + crcSize + len(meta) + len(value)
+}
+
+// encodePage lays out a checksummed record for key as described on Page.
+// transforms are the transformer bits of value, which must already be
+// encoded.
+func encodePage(key string, transforms uint8, meta []byte, value []byte) []byte {
+	pageBuffer := make([]byte, 0, 16+len(key)+crcSize+len(meta)+len(value))
 
 	keySize := uint64(len(key))
 	keySizeBuffer := make([]byte, 8)
@@ -106,55 +706,99 @@ func (kv *KV) Insert(key string, value []byte) error {
 	binary.LittleEndian.PutUint64(keySizeBuffer, keySize)
 	pageBuffer = append(pageBuffer, keySizeBuffer...)
 
-	valueSize := uint64(len(value))
+	valueSize := crcSize + uint64(len(meta)) + uint64(len(value))
+	valueSize |= checksumFlag
+	if meta != nil {
+		valueSize |= metaFlag
+	}
+	valueSize |= uint64(transforms) << transformShift
 	valueSizeBuffer := make([]byte, 8)
 	valueBuffer := []byte(value)
 	binary.LittleEndian.PutUint64(valueSizeBuffer, valueSize)
 	pageBuffer = append(pageBuffer, valueSizeBuffer...)
 
 	pageBuffer = append(pageBuffer, keyBuffer...)
+	checksumAt := len(pageBuffer)
+	pageBuffer = append(pageBuffer, make([]byte, crcSize)...)
+	pageBuffer = append(pageBuffer, meta...)
 	pageBuffer = append(pageBuffer, valueBuffer...)
+	crc := recordChecksum(key, pageBuffer[checksumAt+crcSize:])
+	binary.LittleEndian.PutUint32(pageBuffer[checksumAt:], crc)
 
-	offset, err := kv.f.WriteAt(pageBuffer, int64(kv.lastOffset))
+	return pageBuffer
+}
 
+// readValue reads the value of the record described by page and applies any
+// registered value migration.
+func (kv *KV) readValue(key string, page Page) ([]byte, error) {
+	if page.expired() {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if err := kv.checkGetSize(key, page); err != nil {
+		return nil, err
+	}
+	if value, ok := kv.cache.get(key, page.offset); ok {
+		return value, nil
+	}
+	_, valueBuf, err := kv.readData(key, page)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	page := Page{
-		offset:    kv.lastOffset,
-		size:      uint64(len(keyBuffer)) + uint64(len(valueBuffer)) + 16,
-		valueSize: uint64(len(value)),
-		keySize:   uint64(len(key)),
+	valueBuf, err = kv.decodeValue(page.transforms, valueBuf)
+	if err != nil {
+		return nil, err
 	}
-	kv.pages[key] = page
-	kv.lastOffset += uint64(offset)
-
-	return nil
+	if err := kv.checkDecodedSize(key, uint64(len(valueBuf))); err != nil {
+		return nil, err
+	}
+	valueBuf, err = kv.migrateValue(key, page, valueBuf)
+	if err != nil {
+		return nil, err
+	}
+	kv.cache.add(key, page.offset, valueBuf)
+	return valueBuf, nil
 }
 
-func (kv *KV) Get(key string) (string, error) {
-	if page, ok := kv.pages[key]; ok {
-		valueOffset := page.offset + 8 + 8 + page.keySize
-
-		_, err := kv.f.Seek(int64(valueOffset), 0)
-		if err != nil {
-			log.Panic(err)
-		}
+func (kv *KV) Get(key string) ([]byte, error) {
+	unlock := kv.readLock()
+	defer unlock()
 
-		valueBuf := make([]byte, page.valueSize)
-
-		err = binary.Read(kv.f, binary.LittleEndian, valueBuf)
+	if kv.f == nil {
+		return nil, ErrDBNotOpen
+	}
+	if value, ok := kv.pinnedValue(key); ok {
+		kv.metrics.countGet(true)
+		return append([]byte(nil), value...), nil
+	}
 
+	page, ok := kv.pages[key]
+	kv.metrics.countGet(ok)
+	if ok {
+		valueBuf, err := kv.readValue(key, page)
 		if err != nil {
-			log.Panic(err)
+			return nil, err
 		}
 
-		return string(valueBuf), nil
+		return valueBuf, nil
 	} else {
-		return "", fmt.Errorf("cannot find key %s", key)
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
 	}
 }
 
+// GetString is like Get but returns the value as a string.
+func (kv *KV) GetString(key string) (string, error) {
+	value, err := kv.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// InsertString is like Insert but takes the value as a string.
+func (kv *KV) InsertString(key, value string) error {
+	return kv.Insert(key, []byte(value))
+}
+
 func main() {
 	kv := NewKV()
 	f := kv.Connect()
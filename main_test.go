@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestKV connects a new KV built with opts to a fresh file in a
+// temporary directory, closing it when the test ends.
+func openTestKV(t testing.TB, opts ...Option) *KV {
+	t.Helper()
+	return openTestKVAt(t, filepath.Join(t.TempDir(), "test.db"), Options{}, opts...)
+}
+
+// openTestKVAt connects a new KV built with opts to the file at path.
+func openTestKVAt(t testing.TB, path string, options Options, opts ...Option) *KV {
+	t.Helper()
+	kv := NewKV(opts...)
+	if err := kv.ConnectWithOptions(path, options); err != nil {
+		t.Fatalf("connect %s: %v", path, err)
+	}
+	t.Cleanup(func() { kv.Close() })
+	return kv
+}
+
+// mustInsert inserts key, failing the test on error.
+func mustInsert(t testing.TB, kv *KV, key, value string) {
+	t.Helper()
+	if err := kv.Insert(key, []byte(value)); err != nil {
+		t.Fatalf("insert %s: %v", key, err)
+	}
+}
+
+// wantValue fails the test unless key reads back as want.
+func wantValue(t testing.TB, kv *KV, key, want string) {
+	t.Helper()
+	got, err := kv.Get(key)
+	if err != nil {
+		t.Fatalf("get %s: %v", key, err)
+	}
+	if string(got) != want {
+		t.Fatalf("get %s = %q, want %q", key, got, want)
+	}
+}
+
+// TestConcurrentAccess hammers one database from many goroutines. It is
+// meant to be run with go test -race.
+func TestConcurrentAccess(t *testing.T) {
+	kv := openTestKV(t)
+	const writers, readers, ops = 4, 4, 200
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				key := fmt.Sprintf("key-%d", i%20)
+				if err := kv.Insert(key, []byte(fmt.Sprintf("%d-%d", w, i))); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%10 == 0 {
+					if err := kv.Delete(key); err != nil && !errors.Is(err, ErrKeyNotFound) {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				key := fmt.Sprintf("key-%d", i%20)
+				if _, err := kv.Get(key); err != nil && !errors.Is(err, ErrKeyNotFound) {
+					t.Error(err)
+					return
+				}
+				kv.Exists(key)
+				kv.Len()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := kv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThrottledWriteDoesNotBlockReads(t *testing.T) {
+	kv := openTestKV(t, WithWriteRateLimit(1000))
+	mustInsert(t, kv, "a", "1")
+
+	// This insert is three times the burst, so it waits about two seconds.
+	go kv.Insert("big", make([]byte, 3000))
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	wantValue(t, kv, "a", "1")
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("Get waited %v behind a throttled insert", elapsed)
+	}
+}
+
+func TestGetString(t *testing.T) {
+	kv := openTestKV(t)
+	for key, value := range map[string]string{
+		"ascii":   "hello",
+		"unicode": "héllo wörld, こんにちは 🌍",
+		"empty":   "",
+		"キー":      "unicode key",
+	} {
+		if err := kv.InsertString(key, value); err != nil {
+			t.Fatal(err)
+		}
+		got, err := kv.GetString(key)
+		if err != nil || got != value {
+			t.Fatalf("GetString %s = %q, %v, want %q", key, got, err, value)
+		}
+		wantValue(t, kv, key, value)
+	}
+	if _, err := kv.GetString("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetString missing key: %v", err)
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	kv := openTestKV(t)
+	_, err := kv.Get("missing")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get missing key: %v", err)
+	}
+	if !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("error %q does not name the key", err)
+	}
+
+	for name, kv := range map[string]*KV{"never opened": NewKV(), "closed": openTestKV(t)} {
+		kv.Close()
+		if _, err := kv.Get("a"); !errors.Is(err, ErrDBNotOpen) {
+			t.Errorf("%s: Get = %v", name, err)
+		}
+		if err := kv.Insert("a", []byte("1")); !errors.Is(err, ErrDBNotOpen) {
+			t.Errorf("%s: Insert = %v", name, err)
+		}
+		if err := kv.Delete("a"); !errors.Is(err, ErrDBNotOpen) {
+			t.Errorf("%s: Delete = %v", name, err)
+		}
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	if err := NewKV().Close(); err != nil {
+		t.Fatalf("Close of a never opened database: %v", err)
+	}
+
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	for i := 0; i < 2; i++ {
+		if err := kv.Close(); err != nil {
+			t.Fatalf("Close %d: %v", i+1, err)
+		}
+	}
+
+	if _, err := kv.Get("a"); !errors.Is(err, ErrDBNotOpen) {
+		t.Fatalf("Get after Close: %v", err)
+	}
+	for name, op := range map[string]func() error{
+		"GetTo":      func() error { _, err := kv.GetTo("a", make([]byte, 8)); return err },
+		"GetReader":  func() error { _, err := kv.GetReader("a"); return err },
+		"Scan":       func() error { return kv.Scan(func(string, []byte) bool { return true }) },
+		"ApplyPatch": func() error { return kv.ApplyPatch(Patch{Set: map[string][]byte{"b": []byte("2")}}) },
+		"Compact":    kv.Compact,
+	} {
+		if err := op(); !errors.Is(err, ErrDBNotOpen) {
+			t.Errorf("%s after Close: %v", name, err)
+		}
+	}
+}
+
+func TestReconnectToAnotherFile(t *testing.T) {
+	dir := t.TempDir()
+	kv := openTestKVAt(t, dir+"/a.db", Options{})
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	if err := kv.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	kv.Close()
+
+	for name, connect := range map[string]func(path string) error{
+		"ConnectWithOptions": func(path string) error { return kv.ConnectWithOptions(path, Options{}) },
+		"ConnectAt":          func(path string) error { return kv.ConnectAt(path, uint64(fileHeaderSize)) },
+	} {
+		path := dir + "/" + name + ".db"
+		if err := connect(path); err != nil {
+			t.Fatal(err)
+		}
+		if kv.Len() != 0 || kv.DeadBytes() != 0 || kv.lastOffset != uint64(fileHeaderSize) {
+			t.Fatalf("%s: kept %d keys, %d dead bytes and offset %d from a.db", name, kv.Len(), kv.DeadBytes(), kv.lastOffset)
+		}
+		mustInsert(t, kv, "c", "3")
+		kv.Close()
+
+		reloaded := openTestKVAt(t, path, Options{})
+		if keys, _ := reloaded.KeysPage("", 10); len(keys) != 1 || keys[0] != "c" {
+			t.Fatalf("%s: reloaded keys %q, want [c]", name, keys)
+		}
+		wantValue(t, reloaded, "c", "3")
+		reloaded.Close()
+	}
+}
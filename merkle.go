@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Proof shows that a key/value pair is part of the dataset summarized by a
+// Merkle root.
+type Proof struct {
+	Key   string
+	Value []byte
+	// Steps are the sibling hashes from the leaf up to the root.
+	Steps []ProofStep
+}
+
+// ProofStep is one sibling hash on the path from a leaf to the root.
+type ProofStep struct {
+	Hash []byte
+	// Left is set when the sibling sits to the left of the path.
+	Left bool
+}
+
+// Leaves and interior nodes are hashed with different prefixes so a leaf can
+// never be passed off as a node.
+func merkleLeaf(key string, value []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	binary.Write(h, binary.LittleEndian, uint64(len(key)))
+	h.Write([]byte(key))
+	h.Write(value)
+	return h.Sum(nil)
+}
+
+func merkleNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleLevels builds the tree over every key/value pair in byte order of the
+// keys and returns its levels, leaves first. Byte order is used even with
+// WithComparator so that equal datasets always have equal roots. A node
+// without a sibling is carried up to the next level unchanged.
+func (kv *KV) merkleLevels() ([][][]byte, []string, error) {
+	keys := make([]string, 0, len(kv.pages))
+	for key, page := range kv.pages {
+		if !page.expired() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	leaves := make([][]byte, len(keys))
+	for i, key := range keys {
+		value, err := kv.readValue(key, kv.pages[key])
+		if err != nil {
+			return nil, nil, err
+		}
+		leaves[i] = merkleLeaf(key, value)
+	}
+
+	levels := [][][]byte{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, merkleNode(level[i], level[i+1]))
+			}
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels, keys, nil
+}
+
+// MerkleRoot returns the root hash of a Merkle tree over all key/value pairs
+// in key order. Two databases with the same contents have the same root. The
+// root of an empty database is the hash of no data.
+func (kv *KV) MerkleRoot() ([]byte, error) {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return nil, ErrDBNotOpen
+	}
+
+	levels, _, err := kv.merkleLevels()
+	if err != nil {
+		return nil, err
+	}
+	top := levels[len(levels)-1]
+	if len(top) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:], nil
+	}
+	return top[0], nil
+}
+
+// MerkleProof returns a proof that key and its current value are included in
+// the tree whose root MerkleRoot returns.
+func (kv *KV) MerkleProof(key string) (Proof, error) {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return Proof{}, ErrDBNotOpen
+	}
+
+	page, ok := kv.pages[key]
+	if !ok || page.expired() {
+		return Proof{}, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	value, err := kv.readValue(key, page)
+	if err != nil {
+		return Proof{}, err
+	}
+	levels, keys, err := kv.merkleLevels()
+	if err != nil {
+		return Proof{}, err
+	}
+
+	proof := Proof{Key: key, Value: value}
+	i := sort.SearchStrings(keys, key)
+	for _, level := range levels[:len(levels)-1] {
+		sibling := i ^ 1
+		if sibling < len(level) {
+			proof.Steps = append(proof.Steps, ProofStep{Hash: level[sibling], Left: sibling < i})
+		}
+		i /= 2
+	}
+	return proof, nil
+}
+
+// Verify reports whether the proof shows its key/value pair is included in
+// the tree with the given root.
+func (p Proof) Verify(root []byte) bool {
+	hash := merkleLeaf(p.Key, p.Value)
+	for _, step := range p.Steps {
+		if step.Left {
+			hash = merkleNode(step.Hash, hash)
+		} else {
+			hash = merkleNode(hash, step.Hash)
+		}
+	}
+	return bytes.Equal(hash, root)
+}
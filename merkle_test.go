@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// mustRoot returns the Merkle root of kv, failing the test on error.
+func mustRoot(t *testing.T, kv *KV) []byte {
+	t.Helper()
+	root, err := kv.MerkleRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestMerkleRoot(t *testing.T) {
+	a, b := openTestKV(t), openTestKV(t, WithVarintHeaders())
+	if !bytes.Equal(mustRoot(t, a), mustRoot(t, b)) {
+		t.Fatal("empty databases have different roots")
+	}
+
+	// The same data written in a different order, with overwrites, has the
+	// same root.
+	for i := 0; i < 7; i++ {
+		mustInsert(t, a, fmt.Sprintf("key-%d", i), fmt.Sprint(i))
+	}
+	for i := 6; i >= 0; i-- {
+		mustInsert(t, b, fmt.Sprintf("key-%d", i), "old")
+		mustInsert(t, b, fmt.Sprintf("key-%d", i), fmt.Sprint(i))
+	}
+	root := mustRoot(t, a)
+	if !bytes.Equal(root, mustRoot(t, b)) {
+		t.Fatal("equal datasets have different roots")
+	}
+
+	mustInsert(t, b, "key-3", "changed")
+	if bytes.Equal(root, mustRoot(t, b)) {
+		t.Fatal("changing a value kept the root")
+	}
+	mustInsert(t, b, "key-3", "3")
+	mustInsert(t, b, "key-7", "7")
+	if bytes.Equal(root, mustRoot(t, b)) {
+		t.Fatal("adding a key kept the root")
+	}
+}
+
+func TestMerkleProof(t *testing.T) {
+	kv := openTestKV(t)
+	// An odd number of leaves exercises nodes without a sibling.
+	for i := 0; i < 5; i++ {
+		mustInsert(t, kv, fmt.Sprintf("key-%d", i), fmt.Sprint(i))
+	}
+	root := mustRoot(t, kv)
+
+	for i := 0; i < 5; i++ {
+		proof, err := kv.MerkleProof(fmt.Sprintf("key-%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verify(root) {
+			t.Fatalf("proof of key-%d does not verify", i)
+		}
+		forged := proof
+		forged.Value = []byte("forged")
+		if forged.Verify(root) {
+			t.Fatalf("forged proof of key-%d verifies", i)
+		}
+	}
+
+	proof, _ := kv.MerkleProof("key-0")
+	mustInsert(t, kv, "key-4", "changed")
+	if proof.Verify(mustRoot(t, kv)) {
+		t.Fatal("proof verifies against the root of different data")
+	}
+	if _, err := kv.MerkleProof("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("MerkleProof missing key: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrReservedMeta is returned for metadata names starting with
+// reservedMetaPrefix, which are kept for voila's own entries.
+var ErrReservedMeta = errors.New("metadata name is reserved")
+
+// reservedMetaPrefix starts the names of the metadata entries voila stores
+// for itself, such as the expiry time written by InsertWithTTL. Callers
+// cannot set them and GetWithMeta leaves them out.
+const reservedMetaPrefix = "voila."
+
+// checkMetaNames rejects metadata that uses a reserved name.
+func checkMetaNames(meta map[string]string) error {
+	for name := range meta {
+		if strings.HasPrefix(name, reservedMetaPrefix) {
+			return fmt.Errorf("%w: %s", ErrReservedMeta, name)
+		}
+	}
+	return nil
+}
+
+// encodeMeta serializes meta into a metadata block: an 8 byte length followed
+// by uvarint length-prefixed name/value pairs sorted by name.
+func encodeMeta(meta map[string]string) []byte {
+	names := make([]string, 0, len(meta))
+	for name := range meta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := make([]byte, 8)
+	for _, name := range names {
+		buf = binary.AppendUvarint(buf, uint64(len(name)))
+		buf = append(buf, name...)
+		buf = binary.AppendUvarint(buf, uint64(len(meta[name])))
+		buf = append(buf, meta[name]...)
+	}
+	binary.LittleEndian.PutUint64(buf, uint64(len(buf)-8))
+	return buf
+}
+
+// decodeMeta parses the pairs of a metadata block with its length prefix
+// already stripped.
+func decodeMeta(buf []byte) (map[string]string, error) {
+	meta := make(map[string]string)
+	for len(buf) > 0 {
+		name, rest, ok := readMetaString(buf)
+		if !ok {
+			return nil, fmt.Errorf("malformed metadata block")
+		}
+		value, rest, ok := readMetaString(rest)
+		if !ok {
+			return nil, fmt.Errorf("malformed metadata block")
+		}
+		meta[name] = value
+		buf = rest
+	}
+	return meta, nil
+}
+
+func readMetaString(buf []byte) (string, []byte, bool) {
+	size, n := binary.Uvarint(buf)
+	if n <= 0 || size > uint64(len(buf)-n) {
+		return "", nil, false
+	}
+	buf = buf[n:]
+	return string(buf[:size]), buf[size:], true
+}
+
+// InsertWithMeta stores value under key together with a small metadata map,
+// such as a content type or file name. Names starting with "voila." are
+// reserved and fail with ErrReservedMeta.
+func (kv *KV) InsertWithMeta(key string, value []byte, meta map[string]string) error {
+	if err := checkMetaNames(meta); err != nil {
+		return err
+	}
+	return kv.insertThrottled(context.Background(), key, encodeMeta(meta), value)
+}
+
+// GetWithMeta returns the value stored under key along with its metadata. Keys
+// written without metadata return an empty map.
+func (kv *KV) GetWithMeta(key string) ([]byte, map[string]string, error) {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return nil, nil, ErrDBNotOpen
+	}
+	page, ok := kv.pages[key]
+	kv.metrics.countGet(ok)
+	if !ok || page.expired() {
+		return nil, nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if err := kv.checkGetSize(key, page); err != nil {
+		return nil, nil, err
+	}
+
+	metaBuf, value, err := kv.readData(key, page)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	value, err = kv.decodeValue(page.transforms, value)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := kv.checkDecodedSize(key, uint64(len(value))); err != nil {
+		return nil, nil, err
+	}
+	value, err = kv.migrateValue(key, page, value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if page.metaSize == 0 {
+		return value, map[string]string{}, nil
+	}
+	meta, err := decodeMeta(metaBuf[8:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("key %s: %w", key, err)
+	}
+	for name := range meta {
+		if strings.HasPrefix(name, reservedMetaPrefix) {
+			delete(meta, name)
+		}
+	}
+	return value, meta, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestInsertWithMeta(t *testing.T) {
+	kv := openTestKV(t)
+	tests := []struct {
+		key   string
+		value []byte
+		meta  map[string]string
+	}{
+		{"page", []byte("<html></html>"), map[string]string{"content-type": "text/html", "filename": "index.html"}},
+		{"empty", []byte("v"), map[string]string{}},
+		{"binary", []byte{0, 1, 0xff, 0}, map[string]string{"name": "a\x00b", "": "blank name"}},
+		{"no-value", nil, map[string]string{"k": "v"}},
+	}
+	for _, tt := range tests {
+		if err := kv.InsertWithMeta(tt.key, tt.value, tt.meta); err != nil {
+			t.Fatalf("InsertWithMeta %s: %v", tt.key, err)
+		}
+	}
+	mustInsert(t, kv, "plain", "p")
+
+	check := func() {
+		t.Helper()
+		for _, tt := range tests {
+			value, meta, err := kv.GetWithMeta(tt.key)
+			if err != nil {
+				t.Fatalf("GetWithMeta %s: %v", tt.key, err)
+			}
+			if !bytes.Equal(value, tt.value) {
+				t.Fatalf("GetWithMeta %s value = %q, want %q", tt.key, value, tt.value)
+			}
+			if !reflect.DeepEqual(meta, tt.meta) {
+				t.Fatalf("GetWithMeta %s meta = %v, want %v", tt.key, meta, tt.meta)
+			}
+			// Get returns the value without the metadata block.
+			got, err := kv.Get(tt.key)
+			if err != nil || !bytes.Equal(got, tt.value) {
+				t.Fatalf("Get %s = %q, %v, want %q", tt.key, got, err, tt.value)
+			}
+		}
+		value, meta, err := kv.GetWithMeta("plain")
+		if err != nil || string(value) != "p" || meta == nil || len(meta) != 0 {
+			t.Fatalf("GetWithMeta plain = %q, %v, %v", value, meta, err)
+		}
+	}
+	check()
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// metrics holds running counters for WritePrometheus.
+type metrics struct {
+	inserts      atomic.Uint64
+	deletes      atomic.Uint64
+	bytesWritten atomic.Uint64
+	gets         atomic.Uint64
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+}
+
+func (m *metrics) countInsert(records int, bytes int) {
+	m.inserts.Add(uint64(records))
+	m.bytesWritten.Add(uint64(bytes))
+}
+
+func (m *metrics) countDelete(records int, bytes int) {
+	m.deletes.Add(uint64(records))
+	m.bytesWritten.Add(uint64(bytes))
+}
+
+func (m *metrics) countGet(found bool) {
+	m.gets.Add(1)
+	if found {
+		m.hits.Add(1)
+	} else {
+		m.misses.Add(1)
+	}
+}
+
+// WritePrometheus writes the database's counters and size gauges to w in the
+// Prometheus text exposition format, ready to be served from a /metrics
+// handler.
+func (kv *KV) WritePrometheus(w io.Writer) error {
+	unlock := kv.readLock()
+	defer unlock()
+
+	stats := kv.stats()
+
+	metrics := []struct {
+		name, kind, help string
+		value            uint64
+	}{
+		{"voila_inserts_total", "counter", "Number of values written.", kv.metrics.inserts.Load()},
+		{"voila_deletes_total", "counter", "Number of keys deleted.", kv.metrics.deletes.Load()},
+		{"voila_written_bytes_total", "counter", "Number of record bytes appended to the database file.", kv.metrics.bytesWritten.Load()},
+		{"voila_gets_total", "counter", "Number of value lookups.", kv.metrics.gets.Load()},
+		{"voila_get_hits_total", "counter", "Number of value lookups that found their key.", kv.metrics.hits.Load()},
+		{"voila_get_misses_total", "counter", "Number of value lookups for missing keys.", kv.metrics.misses.Load()},
+		{"voila_keys", "gauge", "Number of keys in the database.", uint64(stats.Keys)},
+		{"voila_file_size_bytes", "gauge", "Size of the database file.", stats.FileBytes},
+		{"voila_live_bytes", "gauge", "Bytes of the database file holding current records.", stats.LiveBytes},
+		{"voila_dead_bytes", "gauge", "Bytes of the database file reclaimable by compaction.", stats.DeadBytes},
+	}
+	for _, m := range metrics {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
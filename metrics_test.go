@@ -0,0 +1,85 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parsePrometheus checks text against the parts of the Prometheus text
+// format WritePrometheus uses and returns the sample values by name.
+func parsePrometheus(t *testing.T, text string) map[string]float64 {
+	t.Helper()
+	name := `[a-zA-Z_:][a-zA-Z0-9_:]*`
+	help := regexp.MustCompile(`^# HELP (` + name + `) \S.*$`)
+	typ := regexp.MustCompile(`^# TYPE (` + name + `) (counter|gauge)$`)
+	sample := regexp.MustCompile(`^(` + name + `) (\S+)$`)
+
+	samples := make(map[string]float64)
+	described := make(map[string]int)
+	if !strings.HasSuffix(text, "\n") {
+		t.Fatal("output does not end in a newline")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if m := help.FindStringSubmatch(line); m != nil {
+			described[m[1]]++
+			continue
+		}
+		if m := typ.FindStringSubmatch(line); m != nil {
+			described[m[1]]++
+			continue
+		}
+		m := sample.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("malformed line %q", line)
+		}
+		if described[m[1]] != 2 {
+			t.Fatalf("sample %s without HELP and TYPE lines before it", m[1])
+		}
+		value, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			t.Fatalf("sample %s has value %q", m[1], m[2])
+		}
+		samples[m[1]] = value
+	}
+	return samples
+}
+
+func TestWritePrometheus(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "a", "2")
+	mustInsert(t, kv, "b", "3")
+	if err := kv.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "a", "2")
+	kv.Get("missing")
+
+	var out strings.Builder
+	if err := kv.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	samples := parsePrometheus(t, out.String())
+	stats := kv.Stats()
+	for name, want := range map[string]float64{
+		"voila_inserts_total":       3,
+		"voila_deletes_total":       1,
+		"voila_written_bytes_total": float64(stats.FileBytes - uint64(fileHeaderSize)),
+		"voila_gets_total":          2,
+		"voila_get_hits_total":      1,
+		"voila_get_misses_total":    1,
+		"voila_keys":                1,
+		"voila_file_size_bytes":     float64(stats.FileBytes),
+		"voila_live_bytes":          float64(stats.LiveBytes),
+		"voila_dead_bytes":          float64(stats.DeadBytes),
+	} {
+		got, ok := samples[name]
+		if !ok {
+			t.Errorf("missing metric %s", name)
+		} else if got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
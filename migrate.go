@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+// MigrationFunc upgrades a value written in an older schema. It returns the
+// new value and whether anything changed.
+type MigrationFunc func(key string, old []byte) ([]byte, bool, error)
+
+// WithValueMigration runs fn on every value as it is read. When fn reports a
+// change, the migrated value is returned to the caller and written back to
+// the database so later reads no longer need migrating.
+func WithValueMigration(fn MigrationFunc) Option {
+	return func(kv *KV) {
+		kv.migrate = fn
+	}
+}
+
+// migrateValue applies the registered migration to value, persisting the
+// result if it changed and the database is writable. Any metadata stored
+// with the old value is kept.
+func (kv *KV) migrateValue(key string, page Page, value []byte) ([]byte, error) {
+	if kv.migrate == nil {
+		return value, nil
+	}
+
+	migrated, changed, err := kv.migrate(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("migrating key %s: %w", key, err)
+	}
+	if !changed {
+		return value, nil
+	}
+	if kv.checkWritable() != nil {
+		return migrated, nil
+	}
+
+	var meta []byte
+	if page.metaSize > 0 {
+		meta = make([]byte, page.metaSize)
+		if _, err := kv.f.ReadAt(meta, int64(page.valueOffset()-page.metaSize)); err != nil {
+			return nil, err
+		}
+	}
+	if err := kv.insert(key, meta, migrated); err != nil {
+		return nil, err
+	}
+	return migrated, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// renameField migrates values from the v1 to the v2 schema, counting the
+// values it changes.
+func renameField(changed *int) MigrationFunc {
+	return func(key string, old []byte) ([]byte, bool, error) {
+		if !bytes.HasPrefix(old, []byte("v1:")) {
+			return old, false, nil
+		}
+		*changed++
+		return append([]byte("v2:"), old[3:]...), true, nil
+	}
+}
+
+func TestValueMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	old := openTestKVAt(t, path, Options{})
+	mustInsert(t, old, "a", "v1:x")
+	mustInsert(t, old, "b", "v2:y")
+	if err := old.InsertWithMeta("m", []byte("v1:z"), map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	var changed int
+	kv := openTestKVAt(t, path, Options{}, WithValueMigration(renameField(&changed)))
+	wantValue(t, kv, "a", "v2:x")
+	wantValue(t, kv, "a", "v2:x")
+	wantValue(t, kv, "b", "v2:y")
+	if changed != 1 {
+		t.Fatalf("migrated %d values, want 1", changed)
+	}
+	value, meta, err := kv.GetWithMeta("m")
+	if err != nil || string(value) != "v2:z" || meta["k"] != "v" {
+		t.Fatalf("GetWithMeta = %q, %v, %v", value, meta, err)
+	}
+	kv.Close()
+
+	// The migrated values were written back to the file.
+	plain := openTestKVAt(t, path, Options{})
+	wantValue(t, plain, "a", "v2:x")
+	if _, meta, err := plain.GetWithMeta("m"); err != nil || meta["k"] != "v" {
+		t.Fatalf("GetWithMeta after write-back = %v, %v", meta, err)
+	}
+}
+
+func TestValueMigrationReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	old := openTestKVAt(t, path, Options{})
+	mustInsert(t, old, "a", "v1:x")
+	old.Close()
+
+	var changed int
+	kv := openTestKVAt(t, path, Options{ReadOnly: true}, WithValueMigration(renameField(&changed)))
+	wantValue(t, kv, "a", "v2:x")
+	wantValue(t, kv, "a", "v2:x")
+	if changed != 2 {
+		t.Fatalf("migrated %d times, want 2 without write-back", changed)
+	}
+
+	failing := openTestKVAt(t, path, Options{ReadOnly: true}, WithValueMigration(func(string, []byte) ([]byte, bool, error) {
+		return nil, false, errors.New("bad schema")
+	}))
+	if _, err := failing.Get("a"); err == nil {
+		t.Fatal("Get with a failing migration succeeded")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// Path returns the path of the database file, or "" if it is not open.
+func (kv *KV) Path() string {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	if kv.f == nil {
+		return ""
+	}
+	return kv.f.Name()
+}
+
+// MoveTo moves the database file to newPath and reopens it there. The file
+// contents do not change, so the in-memory index is kept as it is. Moves
+// across filesystems fall back to copying the file and removing the
+// original. A sidecar index next to the file is moved along with it.
+func (kv *KV) MoveTo(newPath string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.waitForExports()
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+
+	if err := kv.flush(); err != nil {
+		return err
+	}
+
+	oldPath := kv.f.Name()
+	if err := kv.f.Close(); err != nil {
+		return err
+	}
+
+	err := moveFile(oldPath, newPath)
+	path := newPath
+	if err != nil {
+		path = oldPath
+	}
+
+	flag := os.O_RDWR
+	if kv.readOnly {
+		flag = os.O_RDONLY
+	}
+	f, openErr := os.OpenFile(path, flag, os.ModePerm)
+	if openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	kv.f = f
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(indexPath(oldPath)); err == nil {
+		moveFile(indexPath(oldPath), indexPath(newPath))
+	}
+	return nil
+}
+
+func moveFile(oldPath, newPath string) error {
+	err := os.Rename(oldPath, newPath)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	src, err := os.Open(oldPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(newPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(newPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	return os.Remove(oldPath)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMoveTo(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.db"), filepath.Join(dir, "new.db")
+	kv := openTestKVAt(t, oldPath, Options{})
+	mustInsert(t, kv, "a", "1")
+	if err := kv.SaveIndex(indexPath(oldPath)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := kv.MoveTo(newPath); err != nil {
+		t.Fatal(err)
+	}
+	if kv.Path() != newPath {
+		t.Fatalf("Path = %s, want %s", kv.Path(), newPath)
+	}
+	for _, path := range []string{oldPath, indexPath(oldPath)} {
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("%s still exists after MoveTo: %v", filepath.Base(path), err)
+		}
+	}
+	if _, err := os.Stat(indexPath(newPath)); err != nil {
+		t.Fatalf("sidecar was not moved: %v", err)
+	}
+	wantValue(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	kv.Close()
+
+	kv = openTestKVAt(t, newPath, Options{})
+	wantValue(t, kv, "a", "1")
+	wantValue(t, kv, "b", "2")
+
+	// A failed move leaves the database where it was and still usable.
+	if err := kv.MoveTo(filepath.Join(dir, "missing", "db")); err == nil {
+		t.Fatal("MoveTo into a missing directory succeeded")
+	}
+	if kv.Path() != newPath {
+		t.Fatalf("Path after failed MoveTo = %s", kv.Path())
+	}
+	mustInsert(t, kv, "c", "3")
+	wantValue(t, kv, "c", "3")
+}
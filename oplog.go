@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Op identifies the kind of mutation in an operation log.
+type Op byte
+
+const (
+	OpInsert Op = iota + 1
+	OpDelete
+)
+
+// RecordOps starts writing every successful mutation to w so that it can be
+// applied to another database with Replay. Each operation is encoded as a
+// single op byte followed by the record as it is laid out on disk, except that
+// the record header always has the fixed 16 byte layout whether or not either
+// file uses WithVarintHeaders. Values are recorded as encoded by
+// WithTransformers and the replaying database needs the same transformers to
+// decode them. Passing nil stops recording.
+func (kv *KV) RecordOps(w io.Writer) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.opLog = w
+}
+
+func (kv *KV) recordOp(op Op, record []byte) error {
+	if kv.opLog == nil {
+		return nil
+	}
+	if _, err := kv.opLog.Write(append([]byte{byte(op)}, record...)); err != nil {
+		return fmt.Errorf("recording operation: %w", err)
+	}
+	return nil
+}
+
+// Replay reads operations written by RecordOps from r and applies them in
+// order until r is exhausted.
+func (kv *KV) Replay(r io.Reader) error {
+	header := make([]byte, 17)
+	for {
+		_, err := io.ReadFull(r, header)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		op := Op(header[0])
+		keySize := binary.LittleEndian.Uint64(header[1:9])
+		valueSize := binary.LittleEndian.Uint64(header[9:17])
+
+		data := make([]byte, keySize+(valueSize&sizeMask))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("reading operation: %w", err)
+		}
+		key := string(data[:keySize])
+		data = data[keySize:]
+
+		if valueSize&checksumFlag != 0 {
+			if len(data) < crcSize {
+				return fmt.Errorf("malformed operation for key %s", key)
+			}
+			if recordChecksum(key, data[crcSize:]) != binary.LittleEndian.Uint32(data) {
+				return fmt.Errorf("operation for key %s: %w", key, ErrChecksumMismatch)
+			}
+			data = data[crcSize:]
+		}
+
+		var meta []byte
+		if valueSize&metaFlag != 0 {
+			if len(data) < 8 {
+				return fmt.Errorf("malformed operation for key %s", key)
+			}
+			metaSize := binary.LittleEndian.Uint64(data) + 8
+			if metaSize > uint64(len(data)) {
+				return fmt.Errorf("malformed operation for key %s", key)
+			}
+			meta, data = data[:metaSize], data[metaSize:]
+		}
+
+		value, err := kv.decodeValue(uint8(valueSize>>transformShift&0xf), data)
+		if err != nil {
+			return fmt.Errorf("operation for key %s: %w", key, err)
+		}
+
+		if err := kv.throttle(context.Background(), recordSize(key, meta, value)); err != nil {
+			return err
+		}
+		kv.mu.Lock()
+		switch op {
+		case OpInsert:
+			err = kv.insert(key, meta, value)
+		case OpDelete:
+			bucket := metaBucket(meta)
+			if _, ok := kv.bucketPages(bucket, false)[key]; ok {
+				err = kv.removeIn(bucket, key)
+			}
+		default:
+			err = fmt.Errorf("unknown operation %d", op)
+		}
+		kv.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import "os"
+
+// Options are the settings for opening a database file with
+// ConnectWithOptions. The zero value opens the file for reading and writing,
+// creating it with os.ModePerm permissions if it does not exist.
+type Options struct {
+	// ReadOnly opens an existing file for reading only. Writes then fail
+	// with ErrReadOnly, and migrated values are not written back.
+	ReadOnly bool
+	// Perm is the permission bits a new file is created with, before the
+	// umask. Zero means os.ModePerm. Files that later replace the database
+	// file, such as the one written by Compact, and the sidecar index get
+	// the permissions the database file has.
+	Perm os.FileMode
+	// SyncOnWrite is equivalent to WithSyncWrites.
+	SyncOnWrite bool
+	// ChecksumOnLoad is equivalent to WithChecksumOnLoad.
+	ChecksumOnLoad bool
+	// MaxKeySize and MaxValueSize, when not zero, are equivalent to
+	// WithMaxKeySize and WithMaxValueSize.
+	MaxKeySize   uint64
+	MaxValueSize uint64
+	// VarintHeaders is equivalent to WithVarintHeaders.
+	VarintHeaders bool
+	// CacheEntries and CacheBytes, when either is not zero, are equivalent
+	// to WithValueCache.
+	CacheEntries int
+	CacheBytes   int
+}
+
+// Option configures optional behaviour of a KV when passed to NewKV.
+type Option func(*KV)
+
+// WithStaleWriteGuard makes every insert confirm that this handle has seen the
+// whole file before appending to it. If another handle has appended records
+// in the meantime, the insert either reloads the new records and appends
+// after them (catchUp) or fails with ErrStaleIndex.
+func WithStaleWriteGuard(catchUp bool) Option {
+	return func(kv *KV) {
+		kv.staleGuard = true
+		kv.staleCatchUp = catchUp
+	}
+}
+
+// WithStrictLoad makes Connect fail with the offset of the first record it
+// cannot read, instead of quietly indexing only the records before it.
+func WithStrictLoad() Option {
+	return func(kv *KV) {
+		kv.strictLoad = true
+	}
+}
+
+// filePerm returns the permission bits of the open database file, so files
+// that replace it or sit next to it can be given the same ones.
+func (kv *KV) filePerm() os.FileMode {
+	info, err := kv.f.Stat()
+	if err != nil {
+		return os.ModePerm
+	}
+	return info.Mode().Perm()
+}
+
+// createReplacement creates or truncates the file at path with exactly the
+// permission bits perm, regardless of the umask or of a file left there by
+// an earlier attempt.
+func createReplacement(path string, perm os.FileMode) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return f, nil
+}
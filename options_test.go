@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// wantPerm fails the test unless the file at path has permission bits want.
+func wantPerm(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Fatalf("%s has mode %v, want %v", filepath.Base(path), got, want)
+	}
+}
+
+func TestConnectWithOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := NewKV().ConnectWithOptions(path, Options{ReadOnly: true}); err == nil {
+		t.Fatal("read-only connect created a missing file")
+	}
+
+	kv := openTestKVAt(t, path, Options{MaxKeySize: 4, MaxValueSize: 4, SyncOnWrite: true})
+	if err := kv.Insert("long-key", []byte("v")); err == nil {
+		t.Fatal("MaxKeySize not applied")
+	}
+	if err := kv.Insert("k", []byte("long value")); err == nil {
+		t.Fatal("MaxValueSize not applied")
+	}
+	mustInsert(t, kv, "k", "v")
+	kv.Close()
+
+	kv = openTestKVAt(t, path, Options{ReadOnly: true})
+	wantValue(t, kv, "k", "v")
+	if err := kv.Insert("k", []byte("w")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("insert into read-only database: %v", err)
+	}
+}
+
+func TestPermKeptByReplacementFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	kv := openTestKVAt(t, path, Options{Perm: 0o600})
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "a", "2")
+	wantPerm(t, path, 0o600)
+
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	wantPerm(t, path, 0o600)
+
+	extracted := filepath.Join(dir, "extract.db")
+	if err := kv.ExtractTo(extracted, func(string) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	wantPerm(t, extracted, 0o600)
+
+	if err := kv.SaveIndex(indexPath(path)); err != nil {
+		t.Fatal(err)
+	}
+	wantPerm(t, indexPath(path), 0o600)
+
+	var backup bytes.Buffer
+	if err := kv.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+	kv.Close()
+	if err := Restore(path, &backup); err != nil {
+		t.Fatal(err)
+	}
+	wantPerm(t, path, 0o600)
+}
+
+func TestReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	kv.Close()
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ro := openTestKVAt(t, path, Options{ReadOnly: true})
+	wantValue(t, ro, "a", "1")
+	writes := map[string]func() error{
+		"Insert":      func() error { return ro.Insert("b", []byte("2")) },
+		"Delete":      func() error { return ro.Delete("a") },
+		"Compact":     ro.Compact,
+		"TruncateTo":  func() error { return ro.TruncateTo(uint64(fileHeaderSize)) },
+		"SetDefaults": func() error { return ro.SetDefaults(map[string][]byte{"c": nil}) },
+		"ApplyPatch":  func() error { return ro.ApplyPatch(Patch{Removed: []string{"a"}}) },
+		"Reserve": func() error {
+			_, err := ro.Reserve("d", 1)
+			return err
+		},
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s on a read-only database: %v", name, err)
+		}
+	}
+	if err := ro.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Fatal("read-only handle changed the file")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Patch describes the changes needed to bring one database up to date with
+// another.
+type Patch struct {
+	// Set holds the new value of every added or modified key.
+	Set map[string][]byte
+	// Removed lists keys to remove.
+	Removed []string
+}
+
+// MakePatch computes the Patch that turns base into current, reading the
+// values of added and modified keys from current.
+func MakePatch(base, current *KV) (Patch, error) {
+	unlock := readLockBoth(base, current)
+	defer unlock()
+
+	added, modified, removed, err := diff(base, current)
+	if err != nil {
+		return Patch{}, err
+	}
+
+	patch := Patch{Set: make(map[string][]byte), Removed: removed}
+	for _, key := range append(added, modified...) {
+		value, err := current.readValue(key, current.pages[key])
+		if err != nil {
+			return Patch{}, err
+		}
+		patch.Set[key] = value
+	}
+	return patch, nil
+}
+
+// ApplyPatch writes every value and tombstone in patch with a single write to
+// the file and only updates the index once that write has succeeded. Removed
+// keys that are missing, or that the patch also sets, are skipped.
+func (kv *KV) ApplyPatch(patch Patch) error {
+	keys := make([]string, 0, len(patch.Set))
+	for key := range patch.Set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Encode the values and wait for the rate limit before locking. Which
+	// removed keys exist is only known under the lock, so the wait covers a
+	// tombstone for each of them.
+	records := make([][]byte, len(keys))
+	pages := make([]Page, len(keys))
+	throttled := 0
+	for i, key := range keys {
+		stored, transforms, err := kv.encodeValue(patch.Set[key])
+		if err != nil {
+			return err
+		}
+		records[i] = encodePage(key, transforms, nil, stored)
+		pages[i] = Page{
+			valueSize:   uint64(len(stored)),
+			keySize:     uint64(len(key)),
+			transforms:  transforms,
+			checksummed: true,
+		}
+		throttled += len(records[i])
+	}
+	for _, key := range patch.Removed {
+		throttled += recordSize(key, nil, nil)
+	}
+	if err := kv.throttle(context.Background(), throttled); err != nil {
+		return err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	if err := kv.checkWritable(); err != nil {
+		return err
+	}
+	if kv.staleGuard {
+		if err := kv.checkStale(); err != nil {
+			return err
+		}
+	}
+
+	valueSizes := make(map[string]uint64, len(patch.Set))
+	for i, key := range keys {
+		if err := kv.checkWriteSize(uint64(len(key)), uint64(len(patch.Set[key]))); err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+		valueSizes[key] = pages[i].valueSize
+	}
+
+	if len(kv.quotas) > 0 {
+		if err := kv.checkQuota(valueSizes); err != nil {
+			return err
+		}
+	}
+
+	var removed []string
+	for _, key := range patch.Removed {
+		if _, ok := patch.Set[key]; ok {
+			continue
+		}
+		if _, ok := kv.pages[key]; ok {
+			removed = append(removed, key)
+		}
+	}
+
+	var buf []byte
+	tombstones := make([][]byte, len(removed))
+	for i, key := range removed {
+		tombstones[i] = encodeTombstone(key, nil)
+		buf = append(buf, kv.storedRecord(tombstones[i])...)
+	}
+	tombstoneBytes := len(buf)
+
+	for i := range keys {
+		record := kv.storedRecord(records[i])
+		pages[i].offset = kv.lastOffset + uint64(len(buf))
+		pages[i].size = uint64(len(record))
+		buf = append(buf, record...)
+	}
+
+	if err := kv.appendRecords(buf); err != nil {
+		return err
+	}
+
+	kv.lastOffset += uint64(len(buf))
+	for _, key := range removed {
+		kv.retirePage(key)
+		delete(kv.pages, key)
+	}
+	kv.deadBytes += uint64(tombstoneBytes)
+	for i, key := range keys {
+		kv.retirePage(key)
+		kv.pages[key] = pages[i]
+	}
+	kv.metrics.countDelete(len(removed), tombstoneBytes)
+	kv.metrics.countInsert(len(keys), len(buf)-tombstoneBytes)
+	if err := kv.syncWrite(); err != nil {
+		return err
+	}
+
+	for i, key := range removed {
+		kv.notifyWrite(OpDelete, key, nil)
+		if err := kv.recordOp(OpDelete, tombstones[i]); err != nil {
+			return err
+		}
+	}
+	for i, key := range keys {
+		kv.notifyWrite(OpInsert, key, patch.Set[key])
+		if err := kv.recordOp(OpInsert, records[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	a, b := openTestKV(t), openTestKV(t)
+	mustInsert(t, a, "same", "v")
+	mustInsert(t, b, "same", "v")
+	mustInsert(t, a, "changed", "old")
+	mustInsert(t, b, "changed", "new")
+	mustInsert(t, a, "gone", "v")
+	mustInsert(t, b, "added", "v")
+
+	patch, err := MakePatch(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch.Set) != 2 || len(patch.Removed) != 1 {
+		t.Fatalf("MakePatch = %+v", patch)
+	}
+	if err := a.ApplyPatch(patch); err != nil {
+		t.Fatal(err)
+	}
+	wantSameContents(t, a, b)
+
+	// The patch is in the file, not just the index.
+	if err := a.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	wantSameContents(t, a, b)
+	if err := a.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyPatchAllOrNothing(t *testing.T) {
+	kv := openTestKV(t, WithMaxValueSize(4))
+	mustInsert(t, kv, "a", "1")
+	end := kv.lastOffset
+
+	err := kv.ApplyPatch(Patch{
+		Set:     map[string][]byte{"b": []byte("2"), "c": []byte(strings.Repeat("x", 5))},
+		Removed: []string{"a"},
+	})
+	if !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("ApplyPatch with an oversized value: %v", err)
+	}
+	if kv.lastOffset != end || kv.Exists("b") {
+		t.Fatal("failed patch was partly applied")
+	}
+	wantValue(t, kv, "a", "1")
+
+	// Removing a missing key, or one the patch also sets, is not an error.
+	if err := kv.ApplyPatch(Patch{Set: map[string][]byte{"a": []byte("2")}, Removed: []string{"a", "missing"}}); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "a", "2")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Pin loads the values of keys into memory and keeps them there until they
+// are unpinned, so Get never goes to disk for them. Writes to a pinned key
+// update the pinned value.
+func (kv *KV) Pin(keys ...string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+
+	if err := kv.flush(); err != nil {
+		return err
+	}
+
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		page, ok := kv.pages[key]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+		}
+		value, err := kv.readValue(key, page)
+		if err != nil {
+			return err
+		}
+		values[key] = value
+	}
+
+	if kv.pinned == nil {
+		kv.pinned = make(map[string][]byte)
+	}
+	for key, value := range values {
+		kv.pinned[key] = value
+	}
+	return nil
+}
+
+// Unpin releases the pinned values of keys. Keys that are not pinned are
+// ignored.
+func (kv *KV) Unpin(keys ...string) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	for _, key := range keys {
+		delete(kv.pinned, key)
+	}
+}
+
+// updatePin keeps a pinned value in step with a write to its key. Deleting a
+// key drops its pin.
+func (kv *KV) updatePin(op Op, key string, value []byte) {
+	if op == OpDelete {
+		delete(kv.pinned, key)
+		return
+	}
+	if _, ok := kv.pinned[key]; ok {
+		kv.pinned[key] = append([]byte(nil), value...)
+	}
+}
+
+// pinnedValue returns the pinned value of key. A pin only counts while its
+// key is in the index and has not expired, so a pin left over from a key
+// that is gone is never served.
+func (kv *KV) pinnedValue(key string) ([]byte, bool) {
+	value, ok := kv.pinned[key]
+	if !ok {
+		return nil, false
+	}
+	page, ok := kv.pages[key]
+	if !ok || page.expired() {
+		return nil, false
+	}
+	return value, true
+}
+
+// prunePins unpins keys that are no longer in the index, for when pages are
+// dropped without going through a delete.
+func (kv *KV) prunePins() {
+	for key := range kv.pinned {
+		if _, ok := kv.pages[key]; !ok {
+			delete(kv.pinned, key)
+		}
+	}
+}
+
+// refreshPins reads every pinned value again after the index has been
+// rebuilt, since the record a key points at may have changed. Keys that no
+// longer exist are unpinned.
+func (kv *KV) refreshPins() error {
+	kv.prunePins()
+	for key := range kv.pinned {
+		value, err := kv.readValue(key, kv.pages[key])
+		if errors.Is(err, ErrKeyNotFound) {
+			delete(kv.pinned, key)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		kv.pinned[key] = value
+	}
+	return nil
+}
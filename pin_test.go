@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPin(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	if err := kv.Pin("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Pin("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Pin missing key: %v", err)
+	}
+
+	// A pinned value is served from memory, even if the file changes under it.
+	kv.f.WriteAt([]byte("X"), int64(kv.pages["a"].valueOffset()))
+	wantValue(t, kv, "a", "1")
+
+	mustInsert(t, kv, "a", "2")
+	wantValue(t, kv, "a", "2")
+	if err := kv.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kv.pinned["a"]; ok {
+		t.Fatal("delete left the key pinned")
+	}
+}
+
+func TestPinDoesNotOutliveTruncatedKey(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	kv.Flush()
+	end := kv.lastOffset
+	mustInsert(t, kv, "b", "2")
+	mustInsert(t, kv, "a", "3")
+	if err := kv.Pin("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := kv.TruncateTo(end); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get("b"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get truncated pinned key: %v", err)
+	}
+	if swapped, err := kv.CompareAndSwap("b", []byte("2"), []byte("3")); swapped || err != nil {
+		t.Fatalf("CompareAndSwap on truncated key = %v, %v", swapped, err)
+	}
+	// The pin of a key that survived is read again at its older value.
+	wantValue(t, kv, "a", "1")
+}
+
+func TestPinDoesNotOutliveExpiredKey(t *testing.T) {
+	kv := openTestKV(t)
+	if err := kv.InsertWithTTL("t", []byte("v"), 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Pin("t"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := kv.Get("t"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get expired pinned key: %v", err)
+	}
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get("t"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get expired pinned key after Compact: %v", err)
+	}
+	if len(kv.pinned) != 0 {
+		t.Fatalf("Compact left %d pins", len(kv.pinned))
+	}
+}
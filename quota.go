@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrQuotaExceeded = errors.New("prefix quota exceeded")
+
+// WithPrefixQuota limits the live key and value bytes stored under prefix to
+// maxBytes. Values count as stored, so after any WithTransformers encoding.
+// Writes that would go over the limit fail with ErrQuotaExceeded. The option
+// can be given several times for different prefixes.
+func WithPrefixQuota(prefix string, maxBytes uint64) Option {
+	return func(kv *KV) {
+		if kv.quotas == nil {
+			kv.quotas = make(map[string]uint64)
+		}
+		kv.quotas[prefix] = maxBytes
+	}
+}
+
+// PrefixBytes returns the number of key and value bytes currently live under
+// prefix, computed from the index alone. Values count as stored, so after
+// any WithTransformers encoding.
+func (kv *KV) PrefixBytes(prefix string) uint64 {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.prefixBytes(prefix)
+}
+
+func (kv *KV) prefixBytes(prefix string) uint64 {
+	var total uint64
+	for key, page := range kv.pages {
+		if strings.HasPrefix(key, prefix) {
+			total += page.keySize + page.valueSize
+		}
+	}
+	return total
+}
+
+// checkQuota reports whether writing values of the given stored sizes to
+// their keys would take any prefix over its quota.
+func (kv *KV) checkQuota(valueSizes map[string]uint64) error {
+	for prefix, maxBytes := range kv.quotas {
+		usage := kv.prefixBytes(prefix)
+		for key, valueSize := range valueSizes {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			if page, ok := kv.pages[key]; ok {
+				usage -= page.keySize + page.valueSize
+			}
+			usage += uint64(len(key)) + valueSize
+		}
+		if usage > maxBytes {
+			return fmt.Errorf("%w: prefix %q would hold %d bytes, quota is %d", ErrQuotaExceeded, prefix, usage, maxBytes)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrefixQuota(t *testing.T) {
+	// Each tenant/a write below takes 9 key bytes and 1 value byte.
+	kv := openTestKV(t, WithPrefixQuota("tenant/a", 30))
+	for _, key := range []string{"tenant/a1", "tenant/a2", "tenant/a3"} {
+		mustInsert(t, kv, key, "v")
+	}
+	if got := kv.PrefixBytes("tenant/a"); got != 30 {
+		t.Fatalf("PrefixBytes = %d, want 30", got)
+	}
+	if err := kv.Insert("tenant/a4", []byte("v")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("insert over quota: %v", err)
+	}
+	if err := kv.Insert("tenant/a1", []byte("vv")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("growing a value over quota: %v", err)
+	}
+	if kv.Exists("tenant/a4") || kv.PrefixBytes("tenant/a") != 30 {
+		t.Fatal("rejected insert was applied")
+	}
+
+	// Overwriting within the quota, and other prefixes, are unaffected.
+	mustInsert(t, kv, "tenant/a1", "w")
+	mustInsert(t, kv, "tenant/b1", strings.Repeat("x", 100))
+
+	// Deleting frees quota.
+	if err := kv.Delete("tenant/a2"); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "tenant/a4", "v")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithWriteRateLimit throttles inserts so that, averaged over time, no more
+// than bytesPerSec bytes are written to the file per second. Writes may burst
+// up to one second's worth of bytes before they start to block.
+func WithWriteRateLimit(bytesPerSec int) Option {
+	return func(kv *KV) {
+		kv.writeLimiter = newRateLimiter(float64(bytesPerSec))
+	}
+}
+
+// throttle waits until WithWriteRateLimit allows n more bytes to be written,
+// or ctx is done. Writers call it before taking the lock, so that a throttled
+// write never holds up readers; where the records to write depend on what is
+// found under the lock, n is the most that may be written.
+func (kv *KV) throttle(ctx context.Context, n int) error {
+	if kv.writeLimiter == nil {
+		return nil
+	}
+	return kv.writeLimiter.wait(ctx, n)
+}
+
+// rateLimiter is a token bucket holding at most one second's worth of tokens.
+// Waiting for more tokens than are available puts the bucket into debt, which
+// later callers must wait to pay off.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// wait blocks until n tokens have been taken from the bucket or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteRateLimit(t *testing.T) {
+	const rate = 20000
+	kv := openTestKV(t, WithWriteRateLimit(rate))
+	value := strings.Repeat("x", 1000)
+
+	start := time.Now()
+	written := 0
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key-%02d", i)
+		mustInsert(t, kv, key, value)
+		written += recordSize(key, nil, []byte(value))
+	}
+	elapsed := time.Since(start)
+
+	// The first second's worth of bytes may be written in a burst.
+	min := time.Duration(float64(written-rate) / rate * float64(time.Second))
+	if elapsed < min {
+		t.Fatalf("wrote %d bytes in %v, want at least %v at %d bytes/s", written, elapsed, min, rate)
+	}
+}
+
+func TestWriteRateLimitCancel(t *testing.T) {
+	kv := openTestKV(t, WithWriteRateLimit(100))
+	// Use up the burst, leaving the next write to wait about a fifth of a
+	// second.
+	value := strings.Repeat("x", 100-recordSize("a", nil, nil))
+	mustInsert(t, kv, "a", value)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := kv.InsertContext(ctx, "b", []byte("v"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("InsertContext past its deadline: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Fatalf("cancelled insert returned after %v", elapsed)
+	}
+	if kv.Exists("b") {
+		t.Fatal("cancelled insert was written")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "os"
+
+// Reopen reopens the database file at its path and rebuilds the index by
+// scanning it, picking up records written by another process or left behind
+// by a crash. Because the file is opened again by name, this also works after
+// the file has been replaced, for example by Compact on another handle. Any
+// buffered records are written out first. Pinned keys are read again, and
+// unpinned if they no longer exist. If the file cannot be loaded again, the
+// database is left closed, as after a failed Connect, so that no write can
+// land on top of records the index no longer knows about.
+func (kv *KV) Reopen() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.waitForExports()
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	if err := kv.flush(); err != nil {
+		return err
+	}
+
+	flag := os.O_RDWR
+	if kv.readOnly {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(kv.f.Name(), flag, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	kv.f.Close()
+	kv.f = f
+
+	kv.resetIndex()
+	err = kv.checkFileHeader()
+	if err == nil {
+		err = kv.loadFromStorage()
+	}
+	if err == nil {
+		err = kv.checkUnreadTail()
+	}
+	if err == nil {
+		err = kv.refreshPins()
+	}
+	if err != nil {
+		f.Close()
+		kv.f = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	writer := openTestKVAt(t, path, Options{})
+	mustInsert(t, writer, "a", "1")
+	mustInsert(t, writer, "b", "1")
+	reader := openTestKVAt(t, path, Options{ReadOnly: true})
+	if err := reader.Pin("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	mustInsert(t, writer, "a", "2")
+	if err := writer.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, writer, "c", "3")
+	if reader.Exists("c") {
+		t.Fatal("reader saw c before Reopen")
+	}
+	if err := reader.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	wantSameContents(t, writer, reader)
+	if _, ok := reader.pinned["b"]; ok {
+		t.Fatal("Reopen left the deleted key pinned")
+	}
+	wantValue(t, reader, "a", "2")
+
+	// Compact replaces the file, which the reader only sees by name.
+	if err := writer.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, writer, "d", "4")
+	if err := reader.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	wantSameContents(t, writer, reader)
+	if reader.DeadBytes() != 0 {
+		t.Fatalf("DeadBytes after Reopen of the compacted file = %d", reader.DeadBytes())
+	}
+
+	reader.Close()
+	if err := reader.Reopen(); !errors.Is(err, ErrDBNotOpen) {
+		t.Fatalf("Reopen after Close: %v", err)
+	}
+}
+
+func TestReopenFailureCloses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	writer := openTestKVAt(t, path, Options{})
+	for _, key := range []string{"a", "b", "c", "d"} {
+		mustInsert(t, writer, key, key+"-value")
+	}
+	kv := openTestKVAt(t, path, Options{}, WithStrictLoad(), WithChecksumOnLoad())
+	corruptValue(t, writer, "b")
+	writer.Close()
+
+	if err := kv.Reopen(); err == nil {
+		t.Fatal("Reopen of a corrupt file succeeded")
+	}
+	if err := kv.Insert("z", []byte("z")); !errors.Is(err, ErrDBNotOpen) {
+		t.Fatalf("Insert after failed Reopen: %v", err)
+	}
+	kv.Close()
+
+	// Nothing was written over the records after the bad one.
+	kv = openTestKVAt(t, path, Options{ReadOnly: true})
+	wantValue(t, kv, "d", "d-value")
+}
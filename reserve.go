@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+var (
+	ErrOutsideReservation = errors.New("write outside reserved value")
+	ErrReservationOpen    = errors.New("a reserved value is still being written")
+	// ErrReservationLost is returned by a ValueWriter whose database has been
+	// closed since Reserve. Its record may since have been compacted away,
+	// so the writer must not touch the file again.
+	ErrReservationLost = errors.New("reservation no longer belongs to the open database")
+)
+
+// ValueWriter fills in a value reserved with Reserve. The key only becomes
+// visible once Close is called; Abort gives up on the value instead.
+type ValueWriter struct {
+	kv     *KV
+	key    string
+	page   Page
+	pos    int64
+	closed bool
+
+	// crc is the checksum of the key and the first crcLen bytes of the
+	// value, kept up while the value is written in order from the start so
+	// that Close does not have to read it back.
+	crc     uint32
+	crcLen  uint64
+	inOrder bool
+}
+
+// Reserve appends a record for key with room for a value of exactly size
+// bytes and returns a writer for filling it in. The record is flagged as
+// reserved on disk until the writer is closed, so if the writer is aborted or
+// abandoned the space is skipped as slack the next time the database is
+// loaded.
+func (kv *KV) Reserve(key string, size int) (*ValueWriter, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid value size %d", size)
+	}
+	if err := kv.throttle(context.Background(), recordSize(key, nil, nil)+size); err != nil {
+		return nil, err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if kv.f == nil {
+		return nil, ErrDBNotOpen
+	}
+	if err := kv.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := kv.checkWriteSize(uint64(len(key)), uint64(size)); err != nil {
+		return nil, err
+	}
+	if kv.staleGuard {
+		if err := kv.checkStale(); err != nil {
+			return nil, err
+		}
+	}
+	if err := kv.flush(); err != nil {
+		return nil, err
+	}
+
+	if len(kv.quotas) > 0 {
+		if err := kv.checkQuota(map[string]uint64{key: uint64(size)}); err != nil {
+			return nil, err
+		}
+	}
+
+	header := fixedHeader(uint64(len(key)), (crcSize+uint64(size))|checksumFlag|reservedFlag)
+	header = append(header, key...)
+	header = append(header, make([]byte, crcSize)...)
+	header = kv.storedRecord(header)
+
+	page := Page{
+		offset:      kv.lastOffset,
+		size:        uint64(len(header)) + uint64(size),
+		valueSize:   uint64(size),
+		keySize:     uint64(len(key)),
+		checksummed: true,
+	}
+	if _, err := kv.f.WriteAt(header, int64(page.offset)); err != nil {
+		return nil, err
+	}
+	// Make sure the whole region exists on disk, so an abandoned reservation
+	// is not mistaken for a truncated record.
+	end := int64(page.offset + page.size)
+	info, err := kv.f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < end {
+		if err := kv.f.Truncate(end); err != nil {
+			return nil, err
+		}
+	}
+	kv.lastOffset += page.size
+
+	vw := &ValueWriter{kv: kv, key: key, page: page, crc: recordChecksum(key, nil), inOrder: true}
+	if kv.reservations == nil {
+		kv.reservations = make(map[*ValueWriter]struct{})
+	}
+	kv.reservations[vw] = struct{}{}
+	return vw, nil
+}
+
+// WriteAt writes p at offset off within the reserved value.
+func (vw *ValueWriter) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(vw.page.valueSize) {
+		return 0, ErrOutsideReservation
+	}
+	kv := vw.kv
+	kv.mu.RLock()
+	if vw.closed {
+		kv.mu.RUnlock()
+		return 0, errors.New("value writer is closed")
+	}
+	if _, ok := kv.reservations[vw]; !ok || kv.f == nil {
+		kv.mu.RUnlock()
+		return 0, ErrReservationLost
+	}
+	n, err := kv.f.WriteAt(p, int64(vw.page.valueOffset())+off)
+	kv.mu.RUnlock()
+
+	if vw.inOrder && uint64(off) == vw.crcLen {
+		vw.crc = crc32.Update(vw.crc, crc32.IEEETable, p[:n])
+		vw.crcLen += uint64(n)
+	} else {
+		vw.inOrder = false
+	}
+	return n, err
+}
+
+// Write writes p after the bytes written by previous calls to Write.
+func (vw *ValueWriter) Write(p []byte) (int, error) {
+	n, err := vw.WriteAt(p, vw.pos)
+	vw.pos += int64(n)
+	return n, err
+}
+
+// Close finalizes the record and makes the key visible. Any part of the
+// value that was never written reads back as zero bytes.
+func (vw *ValueWriter) Close() error {
+	kv := vw.kv
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if vw.closed {
+		return nil
+	}
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	if _, ok := kv.reservations[vw]; !ok {
+		return ErrReservationLost
+	}
+
+	headerSize := vw.page.size - vw.page.keySize - crcSize - vw.page.valueSize
+	checksumAt := headerSize + vw.page.keySize
+
+	// The record is only read back when something needs the value, or when
+	// it was not written in order and the checksum has to be computed.
+	_, pinned := kv.pinned[vw.key]
+	needValue := len(kv.writeCallbacks) > 0 || kv.opLog != nil || pinned
+	crc := vw.crc
+	var record []byte
+	if needValue || !vw.inOrder || vw.crcLen != vw.page.valueSize {
+		record = make([]byte, vw.page.size)
+		if _, err := kv.f.ReadAt(record, int64(vw.page.offset)); err != nil {
+			return err
+		}
+		crc = recordChecksum(vw.key, record[checksumAt+crcSize:])
+	}
+
+	// Fill in the checksum before clearing the reserved flag, so the record
+	// never looks finished with a checksum that does not match.
+	crcBuf := make([]byte, crcSize)
+	binary.LittleEndian.PutUint32(crcBuf, crc)
+	if _, err := kv.f.WriteAt(crcBuf, int64(vw.page.offset+checksumAt)); err != nil {
+		return err
+	}
+	// The reserved flag sits below the data size in a varint header, so
+	// clearing it never changes the header's length.
+	valueSize := (crcSize + vw.page.valueSize) | checksumFlag
+	header := kv.recordHeader(vw.page.keySize, valueSize)
+	if _, err := kv.f.WriteAt(header, int64(vw.page.offset)); err != nil {
+		return err
+	}
+	vw.closed = true
+	delete(kv.reservations, vw)
+	kv.retirePage(vw.key)
+	kv.pages[vw.key] = vw.page
+	kv.metrics.countInsert(1, int(vw.page.size))
+	if err := kv.syncWrite(); err != nil {
+		return err
+	}
+	if !needValue {
+		return nil
+	}
+
+	copy(record, header)
+	copy(record[checksumAt:], crcBuf)
+	kv.notifyWrite(OpInsert, vw.key, record[vw.page.size-vw.page.valueSize:])
+	return kv.recordOp(OpInsert, append(fixedHeader(vw.page.keySize, valueSize), record[headerSize:]...))
+}
+
+// Abort gives up on the reserved value, leaving the key unchanged and the
+// space as slack for Compact to reclaim. Compact and TruncateTo no longer
+// wait for the writer once it is aborted. Abort after Close does nothing.
+func (vw *ValueWriter) Abort() {
+	kv := vw.kv
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if _, ok := kv.reservations[vw]; ok && !vw.closed {
+		kv.deadBytes += vw.page.size
+		delete(kv.reservations, vw)
+	}
+	vw.closed = true
+}
+
+// InsertReader stores the next size bytes read from r under key, copying
+// them into the file without holding the whole value in memory. If r ends
+// before size bytes have been read, or fails, the key is left unchanged and
+// the space written so far is left as slack for compaction.
+func (kv *KV) InsertReader(key string, r io.Reader, size int64) error {
+	if size < 0 || uint64(size) > uint64(^uint(0)>>1) {
+		return fmt.Errorf("invalid value size %d", size)
+	}
+	vw, err := kv.Reserve(key, int(size))
+	if err != nil {
+		return err
+	}
+	n, err := io.CopyN(vw, r, size)
+	if err != nil {
+		vw.Abort()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: read %d of %d bytes for key %s", io.ErrUnexpectedEOF, n, size, key)
+		}
+		return err
+	}
+	return vw.Close()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestReserve(t *testing.T) {
+	for name, opts := range map[string][]Option{"fixed": nil, "varint": {WithVarintHeaders()}} {
+		t.Run(name, func(t *testing.T) { testReserve(t, openTestKV(t, opts...)) })
+	}
+}
+
+func testReserve(t *testing.T, kv *KV) {
+
+	vw, err := kv.Reserve("big", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get("big"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("key visible before Close: %v", err)
+	}
+	if _, err := vw.WriteAt([]byte("56789"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vw.Write([]byte("01234")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vw.WriteAt([]byte("x"), 10); !errors.Is(err, ErrOutsideReservation) {
+		t.Fatalf("write past reservation: %v", err)
+	}
+	if err := vw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "big", "0123456789")
+	if err := kv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "big", "0123456789")
+}
+
+func TestReserveAbandonedIsSlack(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	if _, err := kv.Reserve("lost", 100); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "b", "2")
+	kv.f.Close()
+	kv.f = nil
+
+	kv = openTestKVAt(t, path, Options{})
+	wantValue(t, kv, "a", "1")
+	wantValue(t, kv, "b", "2")
+	if kv.Has("lost") {
+		t.Fatal("abandoned reservation became visible")
+	}
+	if kv.DeadBytes() == 0 {
+		t.Fatal("abandoned reservation is not counted as dead space")
+	}
+	if err := kv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValueWriterAbort(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	vw, err := kv.Reserve("a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vw.Write([]byte("part"))
+	vw.Abort()
+
+	if _, err := vw.Write([]byte("more")); err == nil {
+		t.Fatal("write after Abort succeeded")
+	}
+	if err := vw.Close(); err != nil {
+		t.Fatalf("Close after Abort: %v", err)
+	}
+	wantValue(t, kv, "a", "1")
+	if kv.DeadBytes() == 0 {
+		t.Fatal("aborted reservation is not counted as dead space")
+	}
+	if err := kv.Compact(); err != nil {
+		t.Fatalf("Compact after Abort: %v", err)
+	}
+	if err := kv.TruncateTo(kv.lastOffset); err != nil {
+		t.Fatalf("TruncateTo after Abort: %v", err)
+	}
+	wantValue(t, kv, "a", "1")
+}
+
+func TestValueWriterAfterReconnect(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	vw, err := kv.Reserve("big", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv.Close()
+
+	if err := kv.ConnectWithOptions(path, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "b", "0123456789")
+
+	// The reserved record is gone, and b may now sit where it was.
+	if _, err := vw.Write([]byte("XXXXXXXXXX")); !errors.Is(err, ErrReservationLost) {
+		t.Fatalf("write to a reservation from before Close: %v", err)
+	}
+	if err := vw.Close(); !errors.Is(err, ErrReservationLost) {
+		t.Fatalf("Close of a reservation from before Close: %v", err)
+	}
+	wantValue(t, kv, "a", "1")
+	wantValue(t, kv, "b", "0123456789")
+	if kv.Has("big") {
+		t.Fatal("lost reservation became visible")
+	}
+}
+
+func TestInsertReader(t *testing.T) {
+	kv := openTestKV(t)
+	const size = 4 << 20
+	if err := kv.InsertReader("k", rand.New(rand.NewSource(1)), size); err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(want)
+	got, err := kv.Get("k")
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("stored %d bytes that differ from the %d read, err %v", len(got), size, err)
+	}
+
+	mustInsert(t, kv, "short", "old")
+	err = kv.InsertReader("short", strings.NewReader("abc"), 10)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("short reader: %v", err)
+	}
+	wantValue(t, kv, "short", "old")
+	err = kv.InsertReader("new", strings.NewReader("abc"), 10)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || kv.Exists("new") {
+		t.Fatalf("short reader for a new key: %v", err)
+	}
+
+	mustInsert(t, kv, "after", "1")
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "short", "old")
+	wantValue(t, kv, "after", "1")
+	if err := kv.Compact(); err != nil {
+		t.Fatalf("Compact after abandoned InsertReader: %v", err)
+	}
+	wantValue(t, kv, "after", "1")
+}
@@ -0,0 +1,55 @@
+package main
+
+import "strings"
+
+// Scan calls fn for every key and its value in sorted key order, stopping as
+// soon as fn returns false. Each value is read just before fn is called for
+// it, so stopping early skips reading the rest. fn runs while the database is
+// locked, so it must not call back into it.
+func (kv *KV) Scan(fn func(key string, value []byte) bool) error {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	return kv.scan(kv.sortedKeys(), fn)
+}
+
+// ScanPrefix is like Scan but only visits keys starting with prefix. An empty
+// prefix visits every key.
+func (kv *KV) ScanPrefix(prefix string, fn func(key string, value []byte) bool) error {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	var keys []string
+	for _, key := range kv.sortedKeys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return kv.scan(keys, fn)
+}
+
+// scan calls fn for each of keys in order until it returns false.
+func (kv *KV) scan(keys []string, fn func(key string, value []byte) bool) error {
+	for _, key := range keys {
+		value, ok := kv.pinnedValue(key)
+		if ok {
+			value = append([]byte(nil), value...)
+		} else {
+			var err error
+			value, err = kv.readValue(key, kv.pages[key])
+			if err != nil {
+				return err
+			}
+		}
+		if !fn(key, value) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	kv := openTestKV(t)
+	for _, key := range []string{"c", "a", "e", "b", "d"} {
+		mustInsert(t, kv, key, "value of "+key)
+	}
+
+	var visited []string
+	err := kv.Scan(func(key string, value []byte) bool {
+		if string(value) != "value of "+key {
+			t.Errorf("Scan passed %q for %s", value, key)
+		}
+		visited = append(visited, key)
+		return true
+	})
+	if err != nil || fmt.Sprint(visited) != "[a b c d e]" {
+		t.Fatalf("Scan visited %q, %v", visited, err)
+	}
+
+	// Stopping early skips reading the remaining values, so the corrupt
+	// value of d is never reached.
+	corruptValue(t, kv, "d")
+	visited = nil
+	err = kv.Scan(func(key string, value []byte) bool {
+		visited = append(visited, key)
+		return key != "b"
+	})
+	if err != nil || fmt.Sprint(visited) != "[a b]" {
+		t.Fatalf("Scan stopped early visited %q, %v", visited, err)
+	}
+
+	kv.Close()
+	if err := kv.Scan(func(string, []byte) bool { return true }); !errors.Is(err, ErrDBNotOpen) {
+		t.Fatalf("Scan on a closed database: %v", err)
+	}
+}
+
+func TestScanPrefix(t *testing.T) {
+	kv := openTestKV(t)
+	for _, key := range []string{"user:2:name", "user:1:name", "user:1:email", "user:10:name", "group:1", "user"} {
+		mustInsert(t, kv, key, "v")
+	}
+
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"user:1:", "[user:1:email user:1:name]"},
+		{"user:1", "[user:10:name user:1:email user:1:name]"},
+		{"user", "[user user:10:name user:1:email user:1:name user:2:name]"},
+		{"group:", "[group:1]"},
+		{"", "[group:1 user user:10:name user:1:email user:1:name user:2:name]"},
+		{"nobody:", "[]"},
+	}
+	for _, tt := range tests {
+		visited := []string{}
+		err := kv.ScanPrefix(tt.prefix, func(key string, value []byte) bool {
+			visited = append(visited, key)
+			return true
+		})
+		if err != nil || fmt.Sprint(visited) != tt.want {
+			t.Errorf("ScanPrefix(%q) visited %q, %v, want %s", tt.prefix, visited, err, tt.want)
+		}
+	}
+
+	var visited []string
+	kv.ScanPrefix("user:", func(key string, value []byte) bool {
+		visited = append(visited, key)
+		return len(visited) < 2
+	})
+	if fmt.Sprint(visited) != "[user:10:name user:1:email]" {
+		t.Fatalf("ScanPrefix stopped early visited %q", visited)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// ValueOffsetLen returns where the value of key lives in the database file,
+// for serving it straight from the file descriptor with something like
+// sendfile. The bytes are returned as stored: neither value migrations nor
+// WithTransformers decoding are applied.
+func (kv *KV) ValueOffsetLen(key string) (off int64, length int64, err error) {
+	unlock := kv.readLock()
+	defer unlock()
+	return kv.valueOffsetLen(key)
+}
+
+func (kv *KV) valueOffsetLen(key string) (off int64, length int64, err error) {
+	if kv.f == nil {
+		return 0, 0, ErrDBNotOpen
+	}
+	page, ok := kv.pages[key]
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	return int64(page.valueOffset()), int64(page.valueSize), nil
+}
+
+// ValueSection returns a reader over exactly the value bytes of key in the
+// database file.
+func (kv *KV) ValueSection(key string) (*io.SectionReader, error) {
+	unlock := kv.readLock()
+	defer unlock()
+
+	off, length, err := kv.valueOffsetLen(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(kv.f, off, length), nil
+}
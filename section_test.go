@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestValueSection(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "first")
+	if err := kv.InsertWithMeta("m", []byte("with metadata"), map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "empty", "")
+	mustInsert(t, kv, "a", "second")
+
+	f, err := os.Open(kv.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for key, want := range map[string]string{"a": "second", "m": "with metadata", "empty": ""} {
+		section, err := kv.ValueSection(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(section)
+		if err != nil || string(got) != want {
+			t.Fatalf("ValueSection %s read %q, %v, want %q", key, got, err, want)
+		}
+
+		off, length, err := kv.ValueOffsetLen(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length != int64(len(want)) {
+			t.Fatalf("ValueOffsetLen %s length = %d, want %d", key, length, len(want))
+		}
+		buf := make([]byte, length)
+		if _, err := f.ReadAt(buf, off); err != nil || string(buf) != want {
+			t.Fatalf("file at ValueOffsetLen %s holds %q, %v", key, buf, err)
+		}
+	}
+
+	if _, err := kv.ValueSection("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("ValueSection missing key: %v", err)
+	}
+	if _, _, err := kv.ValueOffsetLen("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("ValueOffsetLen missing key: %v", err)
+	}
+}
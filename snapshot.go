@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"sync"
+)
+
+// Snapshot is a point-in-time copy of the index. Records are only ever
+// appended, so the offsets it holds keep pointing at the values as they were
+// when the snapshot was taken, even while new writes continue.
+type Snapshot struct {
+	pages map[string]Page
+	// buckets holds the records of every Bucket, for ExportConsistent.
+	// Keys and DiffSnapshots cover the default key space only.
+	buckets    []Page
+	lastOffset uint64
+}
+
+// Snapshot captures the current index, leaving out keys whose TTL has passed.
+func (kv *KV) Snapshot() *Snapshot {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.snapshot()
+}
+
+func (kv *KV) snapshot() *Snapshot {
+	pages := make(map[string]Page, len(kv.pages))
+	for key, page := range kv.pages {
+		if !page.expired() {
+			pages[key] = page
+		}
+	}
+	return &Snapshot{pages: pages, buckets: kv.bucketRecords(), lastOffset: kv.lastOffset}
+}
+
+// Keys returns the keys in the snapshot in byte order.
+func (s *Snapshot) Keys() []string {
+	keys := make([]string, 0, len(s.pages))
+	for key := range s.pages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ExportConsistent writes the database as of the moment it is called to w,
+// as a compacted database file sorted by key and followed by the records of
+// each Bucket. Only taking the snapshot holds
+// the lock; the records are then streamed from the file while writes go on,
+// since new records are appended after the ones being copied. Compact,
+// TruncateTo, Reopen, MoveTo and Close wait for running exports to finish
+// before they touch the file.
+func (kv *KV) ExportConsistent(w io.Writer) error {
+	kv.mu.Lock()
+	if kv.f == nil {
+		kv.mu.Unlock()
+		return ErrDBNotOpen
+	}
+	if err := kv.flush(); err != nil {
+		kv.mu.Unlock()
+		return err
+	}
+	snap := kv.snapshot()
+	f, header := kv.f, kv.fileHeader()
+	if kv.exportsDone == nil {
+		kv.exportsDone = sync.NewCond(&kv.mu)
+	}
+	kv.exports++
+	kv.mu.Unlock()
+
+	defer func() {
+		kv.mu.Lock()
+		kv.exports--
+		kv.exportsDone.Broadcast()
+		kv.mu.Unlock()
+	}()
+	return copyRecords(w, f, header, append(pagesOf(snap.pages, snap.Keys()), snap.buckets...))
+}
+
+// waitForExports blocks until no ExportConsistent is reading the file, for
+// operations that replace, cut or close it. It must be called with the write
+// lock held, which it gives up while waiting.
+func (kv *KV) waitForExports() {
+	for kv.exports > 0 {
+		kv.exportsDone.Wait()
+	}
+}
+
+// DiffSnapshots returns, in sorted order, the keys that differ between two
+// snapshots of the same database: keys present in only one of them and keys
+// that were rewritten in between. Rewritten keys are detected by their record
+// offset, so no values are read. Rewriting a key with an identical value
+// still counts as a change.
+func DiffSnapshots(a, b *Snapshot) (changed []string) {
+	for key, pageA := range a.pages {
+		if pageB, ok := b.pages[key]; !ok || pageA.offset != pageB.offset {
+			changed = append(changed, key)
+		}
+	}
+	for key := range b.pages {
+		if _, ok := a.pages[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
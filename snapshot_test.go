@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockingWriter collects writes, stalling the first one until release is
+// closed.
+type blockingWriter struct {
+	buf     bytes.Buffer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	if w.buf.Len() == 0 {
+		close(w.started)
+		<-w.release
+	}
+	return w.buf.Write(p)
+}
+
+func TestExportConsistentDoesNotBlockWriters(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+
+	w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	exported := make(chan error, 1)
+	go func() { exported <- kv.ExportConsistent(w) }()
+	<-w.started
+
+	inserted := make(chan error, 1)
+	go func() {
+		if err := kv.Insert("a", []byte("changed")); err != nil {
+			inserted <- err
+			return
+		}
+		inserted <- kv.Insert("c", []byte("new"))
+	}()
+	select {
+	case err := <-inserted:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Insert blocked behind ExportConsistent")
+	}
+
+	compacted := make(chan error, 1)
+	go func() { compacted <- kv.Compact() }()
+	select {
+	case <-compacted:
+		t.Fatal("Compact ran while an export was reading the file")
+	case <-time.After(20 * time.Millisecond):
+	}
+	wantValue(t, kv, "a", "changed")
+
+	close(w.release)
+	if err := <-exported; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-compacted; err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "export.db")
+	if err := os.WriteFile(path, w.buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	copied := openTestKVAt(t, path, Options{})
+	wantValue(t, copied, "a", "1")
+	wantValue(t, copied, "b", "2")
+	if copied.Len() != 2 {
+		t.Fatalf("export has %d keys", copied.Len())
+	}
+}
+
+func TestSnapshotAndDiffSnapshots(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	mustInsert(t, kv, "same", "v")
+	before := kv.Snapshot()
+
+	// Rewriting b with the same value still counts as a change.
+	mustInsert(t, kv, "b", "2")
+	mustInsert(t, kv, "c", "3")
+	if err := kv.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	after := kv.Snapshot()
+
+	if keys := fmt.Sprint(before.Keys()); keys != "[a b same]" {
+		t.Fatalf("before keys = %s", keys)
+	}
+	if changed := fmt.Sprint(DiffSnapshots(before, after)); changed != "[a b c]" {
+		t.Fatalf("changed = %s, want [a b c]", changed)
+	}
+	if changed := DiffSnapshots(after, after); len(changed) != 0 {
+		t.Fatalf("snapshot differs from itself: %q", changed)
+	}
+}
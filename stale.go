@@ -0,0 +1,29 @@
+package main
+
+import "errors"
+
+var ErrStaleIndex = errors.New("index is behind the end of the database file")
+
+// checkStale compares lastOffset, less any buffered records, with the size of
+// the file on disk. Writing at lastOffset when the file is longer would
+// overwrite records appended by another handle.
+func (kv *KV) checkStale() error {
+	info, err := kv.f.Stat()
+	if err != nil {
+		return err
+	}
+	if uint64(info.Size()) == kv.lastOffset-uint64(len(kv.pending)) {
+		return nil
+	}
+	if !kv.staleCatchUp || len(kv.pending) > 0 {
+		return ErrStaleIndex
+	}
+
+	if err := kv.loadFromStorage(); err != nil {
+		return err
+	}
+	if uint64(info.Size()) != kv.lastOffset {
+		return ErrStaleIndex
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaleWriteGuard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	a := openTestKVAt(t, path, Options{}, WithStaleWriteGuard(true))
+	b := openTestKVAt(t, path, Options{}, WithStaleWriteGuard(true))
+
+	mustInsert(t, a, "a", "1")
+	// b has not seen a's record, so it catches up before appending after it.
+	mustInsert(t, b, "b", "2")
+	wantValue(t, b, "a", "1")
+	mustInsert(t, a, "c", "3")
+
+	check := openTestKVAt(t, path, Options{ReadOnly: true})
+	for key, want := range map[string]string{"a": "1", "b": "2", "c": "3"} {
+		wantValue(t, check, key, want)
+	}
+}
+
+func TestStaleWriteGuardWithoutCatchUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	a := openTestKVAt(t, path, Options{}, WithStaleWriteGuard(false))
+	b := openTestKVAt(t, path, Options{}, WithStaleWriteGuard(false))
+
+	mustInsert(t, a, "a", "1")
+	if err := b.Insert("b", []byte("2")); !errors.Is(err, ErrStaleIndex) {
+		t.Fatalf("insert from stale handle: %v", err)
+	}
+	if err := b.Delete("a"); !errors.Is(err, ErrStaleIndex) {
+		t.Fatalf("delete from stale handle: %v", err)
+	}
+	if err := b.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, b, "b", "2")
+
+	check := openTestKVAt(t, path, Options{ReadOnly: true})
+	wantValue(t, check, "a", "1")
+	wantValue(t, check, "b", "2")
+}
@@ -0,0 +1,58 @@
+package main
+
+// Stats describes how the database file is used, for tracking fragmentation
+// over time and deciding when to Compact.
+type Stats struct {
+	// Keys counts the keys of the default key space and of every Bucket.
+	Keys int
+	// FileBytes is the current size of the database file.
+	FileBytes uint64
+	// LiveBytes is the size of the records the index points at.
+	LiveBytes uint64
+	// DeadBytes is the space DeadBytes reports: overwritten and deleted
+	// records, tombstones and abandoned reservations.
+	DeadBytes uint64
+	// AvgValueSize is the mean size of the live values as stored, so after
+	// any WithTransformers encoding. It is 0 when there are no keys.
+	AvgValueSize float64
+	// CacheHits and CacheMisses count reads answered from and missing the
+	// WithValueCache cache. Both stay 0 without a cache.
+	CacheHits   uint64
+	CacheMisses uint64
+}
+
+// Stats reports key count and space usage from the in-memory index and the
+// file size, without reading any records.
+func (kv *KV) Stats() Stats {
+	unlock := kv.readLock()
+	defer unlock()
+	return kv.stats()
+}
+
+// stats computes Stats. It is shared by everything that reports space usage,
+// so CompactCost, DumpSummary and WritePrometheus agree with it.
+func (kv *KV) stats() Stats {
+	stats := Stats{Keys: len(kv.pages), DeadBytes: kv.deadBytes}
+	stats.CacheHits, stats.CacheMisses = kv.cache.counts()
+	var valueBytes uint64
+	for _, page := range kv.pages {
+		stats.LiveBytes += page.size
+		valueBytes += page.valueSize
+	}
+	for _, pages := range kv.buckets {
+		stats.Keys += len(pages)
+		for _, page := range pages {
+			stats.LiveBytes += page.size
+			valueBytes += page.valueSize
+		}
+	}
+	if stats.Keys > 0 {
+		stats.AvgValueSize = float64(valueBytes) / float64(stats.Keys)
+	}
+	if kv.f != nil {
+		if info, err := kv.f.Stat(); err == nil {
+			stats.FileBytes = uint64(info.Size())
+		}
+	}
+	return stats
+}
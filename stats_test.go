@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "a", "22")
+	mustInsert(t, kv, "b", "333")
+	mustInsert(t, kv, "c", "4444")
+	if err := kv.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := kv.Stats()
+	if stats.Keys != 2 {
+		t.Fatalf("Keys = %d", stats.Keys)
+	}
+	if stats.AvgValueSize != 2.5 {
+		t.Fatalf("AvgValueSize = %v", stats.AvgValueSize)
+	}
+	if stats.DeadBytes != kv.DeadBytes() || stats.DeadBytes == 0 {
+		t.Fatalf("Stats DeadBytes %d, DeadBytes() %d", stats.DeadBytes, kv.DeadBytes())
+	}
+	if stats.FileBytes != uint64(fileHeaderSize)+stats.LiveBytes+stats.DeadBytes {
+		t.Fatalf("file %d bytes is not header + live %d + dead %d", stats.FileBytes, stats.LiveBytes, stats.DeadBytes)
+	}
+
+	cost := kv.CompactCost()
+	if cost.LiveRecords != stats.Keys || cost.LiveBytes != stats.LiveBytes ||
+		cost.FileBytes != stats.FileBytes || cost.ReclaimableBytes != stats.DeadBytes {
+		t.Fatalf("CompactCost %+v disagrees with Stats %+v", cost, stats)
+	}
+
+	var metrics strings.Builder
+	kv.WritePrometheus(&metrics)
+	if !strings.Contains(metrics.String(), "voila_keys 2\n") {
+		t.Fatalf("metrics disagree with Stats:\n%s", metrics.String())
+	}
+
+	if err := kv.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after := kv.Stats()
+	if after.DeadBytes != 0 || after.LiveBytes != stats.LiveBytes || after.FileBytes != uint64(fileHeaderSize)+after.LiveBytes {
+		t.Fatalf("after Compact: %+v", after)
+	}
+	if stats.FileBytes-after.FileBytes != cost.ReclaimableBytes {
+		t.Fatalf("Compact reclaimed %d bytes, estimate was %d", stats.FileBytes-after.FileBytes, cost.ReclaimableBytes)
+	}
+}
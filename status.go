@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Status describes the state of a database handle and which optional
+// features it was set up with, for diagnosing a running instance.
+type Status struct {
+	Open     bool
+	ReadOnly bool
+	Path     string
+	Keys     int
+
+	StaleWriteGuard bool
+	StrictLoad      bool
+	StrictChecks    bool
+	ValueMigration  bool
+	RecordingOps    bool
+	SyncWrites      bool
+	WriteCallbacks  int
+	// WriteRateLimit is the write limit in bytes per second, or 0 if writes
+	// are not throttled.
+	WriteRateLimit float64
+	// MaxGetSize is the largest value Get will return, or 0 for no limit.
+	MaxGetSize   uint64
+	MaxKeySize   uint64
+	MaxValueSize uint64
+	PrefixQuotas int
+	PinnedKeys   int
+	// ExpirySweep reports whether a sweep started by StartExpirySweep is
+	// running.
+	ExpirySweep bool
+	// CacheEntries and CacheBytes are how many values the WithValueCache
+	// cache holds and their total size; CacheMaxEntries and CacheMaxBytes
+	// are its limits. All are 0 without a cache.
+	CacheEntries    int
+	CacheBytes      int
+	CacheMaxEntries int
+	CacheMaxBytes   int
+}
+
+// Status reports the current state of the database.
+func (kv *KV) Status() Status {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	status := Status{
+		Keys:            len(kv.pages),
+		ReadOnly:        kv.readOnly,
+		StaleWriteGuard: kv.staleGuard,
+		StrictLoad:      kv.strictLoad,
+		StrictChecks:    kv.strictChecks,
+		ValueMigration:  kv.migrate != nil,
+		RecordingOps:    kv.opLog != nil,
+		SyncWrites:      kv.syncWrites,
+		WriteCallbacks:  len(kv.writeCallbacks),
+		MaxGetSize:      kv.maxGetSize,
+		MaxKeySize:      kv.maxKeySize,
+		MaxValueSize:    kv.maxValueSize,
+		PrefixQuotas:    len(kv.quotas),
+		PinnedKeys:      len(kv.pinned),
+		ExpirySweep:     kv.sweepStop != nil,
+	}
+	if kv.f != nil {
+		_, err := kv.f.Stat()
+		status.Open = !errors.Is(err, os.ErrClosed)
+		status.Path = kv.f.Name()
+	}
+	if kv.writeLimiter != nil {
+		status.WriteRateLimit = kv.writeLimiter.rate
+	}
+	if kv.cache != nil {
+		status.CacheEntries, status.CacheBytes = kv.cache.usage()
+		status.CacheMaxEntries, status.CacheMaxBytes = kv.cache.maxEntries, kv.cache.maxBytes
+	}
+	return status
+}
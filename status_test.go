@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	plain := openTestKV(t)
+	mustInsert(t, plain, "a", "1")
+	status := plain.Status()
+	want := Status{
+		Open:         true,
+		Path:         plain.Path(),
+		Keys:         1,
+		MaxKeySize:   defaultMaxKeySize,
+		MaxValueSize: defaultMaxValueSize,
+	}
+	if status != want {
+		t.Fatalf("Status without options = %+v, want %+v", status, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{SyncOnWrite: true},
+		WithStaleWriteGuard(true),
+		WithWriteRateLimit(1<<20),
+		WithMaxGetSize(100),
+		WithPrefixQuota("a/", 100),
+		WithPrefixQuota("b/", 100),
+		WithWriteCallback(func(Op, string, []byte) {}))
+	mustInsert(t, kv, "a/1", "1")
+	if err := kv.Pin("a/1"); err != nil {
+		t.Fatal(err)
+	}
+	kv.RecordOps(&bytes.Buffer{})
+
+	status = kv.Status()
+	if !status.Open || status.ReadOnly || status.Path != path || status.Keys != 1 ||
+		!status.StaleWriteGuard || !status.SyncWrites || !status.RecordingOps ||
+		status.WriteCallbacks != 1 || status.WriteRateLimit != 1<<20 || status.MaxGetSize != 100 ||
+		status.PrefixQuotas != 2 || status.PinnedKeys != 1 {
+		t.Fatalf("Status with options = %+v", status)
+	}
+
+	kv.RecordOps(nil)
+	kv.Unpin("a/1")
+	if status := kv.Status(); status.RecordingOps || status.PinnedKeys != 0 {
+		t.Fatalf("Status after turning features off = %+v", status)
+	}
+	kv.Close()
+	if status := kv.Status(); status.Open || status.Path != "" {
+		t.Fatalf("Status after Close = %+v", status)
+	}
+
+	ro := openTestKVAt(t, path, Options{ReadOnly: true})
+	if !ro.Status().ReadOnly {
+		t.Fatal("Status does not report read-only mode")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// GetReader returns a reader over the value stored under key that reads it
+// from the file as it goes, so large values never have to fit in memory. The
+// reader stops at the end of the value. The record's checksum is checked as
+// the value is read, and a mismatch is reported by the Read that reaches the
+// end, in place of io.EOF. Values encoded with WithTransformers, or read while
+// a value migration is set, are decoded into memory first. The reader reads
+// the file as it is when each Read is made; after Compact or MoveTo it fails
+// and has to be opened again.
+func (kv *KV) GetReader(key string) (io.ReadCloser, error) {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return nil, ErrDBNotOpen
+	}
+	if value, ok := kv.pinnedValue(key); ok {
+		kv.metrics.countGet(true)
+		return io.NopCloser(bytes.NewReader(append([]byte(nil), value...))), nil
+	}
+
+	page, ok := kv.pages[key]
+	kv.metrics.countGet(ok)
+	if !ok || page.expired() {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if page.transforms != 0 || kv.migrate != nil {
+		value, err := kv.readValue(key, page)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(value)), nil
+	}
+
+	r := &valueReader{
+		section: io.NewSectionReader(kv.f, int64(page.valueOffset()), int64(page.valueSize)),
+		key:     key,
+		offset:  page.offset,
+	}
+	if page.checksummed {
+		head := make([]byte, crcSize+page.metaSize)
+		if _, err := kv.f.ReadAt(head, int64(page.valueOffset()-page.metaSize-crcSize)); err != nil {
+			return nil, err
+		}
+		r.checked = true
+		r.want = binary.LittleEndian.Uint32(head)
+		r.crc = recordChecksum(key, head[crcSize:])
+	}
+	return io.NopCloser(r), nil
+}
+
+// valueReader reads a value from the file, checking the record checksum once
+// the end of the value is reached.
+type valueReader struct {
+	section *io.SectionReader
+	key     string
+	offset  uint64
+	checked bool
+	want    uint32
+	crc     uint32
+}
+
+func (r *valueReader) Read(p []byte) (int, error) {
+	n, err := r.section.Read(p)
+	if r.checked {
+		r.crc = crc32.Update(r.crc, crc32.IEEETable, p[:n])
+		if err == io.EOF && r.crc != r.want {
+			return n, fmt.Errorf("%w: key %s at offset %d", ErrChecksumMismatch, r.key, r.offset)
+		}
+	}
+	return n, err
+}
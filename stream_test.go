@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestGetReader(t *testing.T) {
+	large := make([]byte, 1<<20)
+	for i := range large {
+		large[i] = byte(i * 7)
+	}
+	for name, opts := range map[string][]Option{
+		"plain":       nil,
+		"transformed": {WithTransformers(xorTransformer{0x5a})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			kv := openTestKV(t, opts...)
+			if err := kv.InsertWithMeta("large", large, map[string]string{"type": "bin"}); err != nil {
+				t.Fatal(err)
+			}
+			mustInsert(t, kv, "next", "must not be read")
+
+			r, err := kv.GetReader("large")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			var got bytes.Buffer
+			chunk := make([]byte, 1000)
+			for {
+				n, err := r.Read(chunk)
+				got.Write(chunk[:n])
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(got.Bytes(), large) {
+				t.Fatalf("read %d bytes that differ from the %d stored", got.Len(), len(large))
+			}
+
+			if _, err := kv.GetReader("missing"); !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("GetReader missing key: %v", err)
+			}
+		})
+	}
+}
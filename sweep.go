@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// StartExpirySweep starts a goroutine that removes keys whose TTL has passed
+// every interval, appending a tombstone for each like Delete does. Expired
+// keys already read as missing; sweeping them turns their records into dead
+// bytes that Compact can reclaim. A sweep that is already running is stopped
+// first. The sweep stops with StopExpirySweep or Close.
+func (kv *KV) StartExpirySweep(interval time.Duration) {
+	kv.StopExpirySweep()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	kv.mu.Lock()
+	kv.sweepStop, kv.sweepDone = stop, done
+	kv.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := kv.sweepExpired(); err != nil {
+					log.Printf("expiry sweep: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// StopExpirySweep stops the sweep started by StartExpirySweep and waits for
+// it to finish. It does nothing if no sweep is running.
+func (kv *KV) StopExpirySweep() {
+	kv.mu.Lock()
+	stop, done := kv.sweepStop, kv.sweepDone
+	kv.sweepStop, kv.sweepDone = nil, nil
+	kv.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (kv *KV) sweepExpired() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if kv.f == nil || kv.readOnly {
+		return nil
+	}
+	var expired []string
+	for key, page := range kv.pages {
+		if page.expired() {
+			expired = append(expired, key)
+		}
+	}
+	sort.Strings(expired)
+	for _, key := range expired {
+		if err := kv.remove(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpirySweep(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "keep", "v")
+	if err := kv.InsertWithTTL("gone", []byte("v"), 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	kv.StartExpirySweep(5 * time.Millisecond)
+	if !kv.Status().ExpirySweep {
+		t.Fatal("Status does not report the running sweep")
+	}
+	deadline := time.Now().Add(time.Second)
+	for kv.Exists("gone") {
+		if time.Now().After(deadline) {
+			t.Fatal("sweep did not remove the expired key")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	kv.StopExpirySweep()
+	if kv.Status().ExpirySweep {
+		t.Fatal("Status reports a stopped sweep")
+	}
+
+	if !kv.Exists("keep") {
+		t.Fatal("sweep removed a key without a TTL")
+	}
+	if kv.DeadBytes() == 0 {
+		t.Fatal("sweep did not turn the expired record into dead bytes")
+	}
+	kv.StopExpirySweep()
+}
+
+func TestCloseStopsExpirySweep(t *testing.T) {
+	kv := openTestKV(t)
+	kv.StartExpirySweep(time.Millisecond)
+	kv.StartExpirySweep(time.Millisecond)
+	if err := kv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if kv.Status().ExpirySweep {
+		t.Fatal("sweep still running after Close")
+	}
+}
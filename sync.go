@@ -0,0 +1,39 @@
+package main
+
+// WithSyncWrites makes every write wait until its records have been synced
+// to stable storage before returning, trading write speed for durability.
+func WithSyncWrites() Option {
+	return func(kv *KV) {
+		kv.syncWrites = true
+	}
+}
+
+// Sync writes out any buffered records and commits the file to stable
+// storage. Without Sync or WithSyncWrites, writes that have returned can
+// still be lost in a crash while the operating system holds them in its
+// cache. Sync does nothing on a database that is not open.
+func (kv *KV) Sync() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if kv.f == nil {
+		return nil
+	}
+	return kv.sync()
+}
+
+// syncWrite is called by every write path once its records are in place, to
+// sync them when WithSyncWrites is set.
+func (kv *KV) syncWrite() error {
+	if !kv.syncWrites {
+		return nil
+	}
+	return kv.sync()
+}
+
+func (kv *KV) sync() error {
+	if err := kv.flush(); err != nil {
+		return err
+	}
+	return kv.f.Sync()
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestSync(t *testing.T) {
+	kv := openTestKV(t, WithWriteBuffer(1024))
+	mustInsert(t, kv, "a", "1")
+	if err := kv.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if size := fileSize(t, kv); uint64(size) != kv.lastOffset {
+		t.Fatalf("file is %d bytes after Sync, want %d", size, kv.lastOffset)
+	}
+
+	kv.Close()
+	if err := kv.Sync(); err != nil {
+		t.Fatalf("Sync on a closed database: %v", err)
+	}
+	if err := NewKV().Sync(); err != nil {
+		t.Fatalf("Sync on a database never opened: %v", err)
+	}
+}
+
+func TestSyncWrites(t *testing.T) {
+	// Syncing each write also writes out the write buffer.
+	kv := openTestKV(t, WithSyncWrites(), WithWriteBuffer(1024))
+	mustInsert(t, kv, "a", "1")
+	if size := fileSize(t, kv); uint64(size) != kv.lastOffset {
+		t.Fatalf("file is %d bytes after a synced insert, want %d", size, kv.lastOffset)
+	}
+	if err := kv.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if size := fileSize(t, kv); uint64(size) != kv.lastOffset {
+		t.Fatalf("file is %d bytes after a synced delete, want %d", size, kv.lastOffset)
+	}
+}
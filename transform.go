@@ -0,0 +1,75 @@
+package main
+
+import "fmt"
+
+// Transformer is one stage of a value encoding pipeline, such as compression
+// or encryption. Decode must undo Encode.
+type Transformer interface {
+	Encode(value []byte) ([]byte, error)
+	Decode(value []byte) ([]byte, error)
+}
+
+// A Transformer that also implements skipper is only applied to values for
+// which Skip returns false. Whether it was applied is recorded per record.
+type skipper interface {
+	Skip(value []byte) bool
+}
+
+// maxTransformers is the number of transform bits in a record's value size.
+const maxTransformers = 4
+
+// WithTransformers encodes every value written through ts, in order, and
+// decodes values read back in reverse order. Each record marks which stages
+// of the pipeline were applied to it, so records written before the pipeline
+// was configured, or before a stage was appended to it, still read back
+// correctly. Reordering or removing stages makes existing records
+// unreadable. Transformers from repeated options, including
+// WithCompression, are appended to the pipeline in the order the options are
+// given. At most four transformers are supported.
+//
+// Values written with Reserve, and bytes read directly from the file with
+// ValueSection, bypass the pipeline.
+func WithTransformers(ts ...Transformer) Option {
+	return func(kv *KV) {
+		kv.transformers = append(kv.transformers, ts...)
+		if len(kv.transformers) > maxTransformers {
+			panic(fmt.Sprintf("voila: at most %d transformers are supported", maxTransformers))
+		}
+	}
+}
+
+// encodeValue runs value through every configured transformer and returns
+// the result along with the bits recording which were applied.
+func (kv *KV) encodeValue(value []byte) ([]byte, uint8, error) {
+	var applied uint8
+	for i, t := range kv.transformers {
+		if s, ok := t.(skipper); ok && s.Skip(value) {
+			continue
+		}
+		encoded, err := t.Encode(value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("encoding value: %w", err)
+		}
+		value = encoded
+		applied |= 1 << i
+	}
+	return value, applied, nil
+}
+
+// decodeValue reverses the transformers recorded in applied.
+func (kv *KV) decodeValue(applied uint8, value []byte) ([]byte, error) {
+	for i := maxTransformers - 1; i >= 0; i-- {
+		if applied&(1<<i) == 0 {
+			continue
+		}
+		if i >= len(kv.transformers) {
+			return nil, fmt.Errorf("value was encoded by transformer %d, which is not configured", i)
+		}
+		decoded, err := kv.transformers[i].Decode(value)
+		if err != nil {
+			return nil, fmt.Errorf("decoding value: %w", err)
+		}
+		value = decoded
+	}
+	return value, nil
+}
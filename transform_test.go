@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// xorTransformer flips every byte with mask, standing in for encryption.
+type xorTransformer struct {
+	mask byte
+}
+
+func (x xorTransformer) Encode(value []byte) ([]byte, error) {
+	out := make([]byte, len(value))
+	for i, b := range value {
+		out[i] = b ^ x.mask
+	}
+	return out, nil
+}
+
+func (x xorTransformer) Decode(value []byte) ([]byte, error) {
+	return x.Encode(value)
+}
+
+func TestTransformers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "plain", "written before the pipeline")
+	kv.Close()
+
+	kv = openTestKVAt(t, path, Options{}, WithTransformers(xorTransformer{0x5a}), WithCompression(0))
+	value := bytes.Repeat([]byte("compressible "), 100)
+	if err := kv.Insert("encoded", value); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "encoded", string(value))
+	wantValue(t, kv, "plain", "written before the pipeline")
+
+	page := kv.pages["encoded"]
+	if page.transforms != 0b11 {
+		t.Fatalf("transforms = %b, want both stages", page.transforms)
+	}
+	if page.valueSize >= uint64(len(value)) {
+		t.Fatalf("stored %d bytes for a %d byte value", page.valueSize, len(value))
+	}
+	stored := make([]byte, page.valueSize)
+	kv.f.ReadAt(stored, int64(page.valueOffset()))
+	if bytes.Contains(stored, []byte("compressible")) {
+		t.Fatal("value stored without encoding")
+	}
+
+	// Without the pipeline the encoded record cannot be read.
+	kv.Close()
+	kv = openTestKVAt(t, path, Options{})
+	if _, err := kv.Get("encoded"); err == nil {
+		t.Fatal("read an encoded value without its transformers")
+	}
+}
+
+func TestMaxGetSizeAppliesToDecodedValue(t *testing.T) {
+	kv := openTestKV(t, WithCompression(0), WithMaxGetSize(100))
+	if err := kv.Insert("big", make([]byte, 10000)); err != nil {
+		t.Fatal(err)
+	}
+	if kv.pages["big"].valueSize > 100 {
+		t.Fatalf("compressed to %d bytes, test needs it under the limit", kv.pages["big"].valueSize)
+	}
+	if _, err := kv.Get("big"); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("Get of a value decoding past the limit: %v", err)
+	}
+	if _, err := kv.GetTo("big", make([]byte, 20000)); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("GetTo of a value decoding past the limit: %v", err)
+	}
+
+	if err := kv.InsertWithMeta("meta", make([]byte, 10000), map[string]string{"type": "zeros"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := kv.GetWithMeta("meta"); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("GetWithMeta of a value decoding past the limit: %v", err)
+	}
+}
+
+func TestQuotaCountsStoredBytes(t *testing.T) {
+	kv := openTestKV(t, WithCompression(0), WithPrefixQuota("p/", 200))
+	value := make([]byte, 10000)
+	if err := kv.Insert("p/a", value); err != nil {
+		t.Fatalf("insert of a value compressing under the quota: %v", err)
+	}
+	if err := kv.ApplyPatch(Patch{Set: map[string][]byte{"p/b": value}}); err != nil {
+		t.Fatalf("patch with a value compressing under the quota: %v", err)
+	}
+	want := kv.pages["p/a"].keySize + kv.pages["p/a"].valueSize + kv.pages["p/b"].keySize + kv.pages["p/b"].valueSize
+	if got := kv.PrefixBytes("p/"); got != want {
+		t.Fatalf("PrefixBytes = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// TruncateTo rolls the database back to the state it had when the file ended
+// at offset by cutting off every record written after it. offset must fall on
+// a record boundary; otherwise the file is left untouched and an error is
+// returned. Like Compact, it fails with ErrReservationOpen while a value from
+// Reserve is still being written.
+func (kv *KV) TruncateTo(offset uint64) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.truncateTo(offset)
+}
+
+func (kv *KV) truncateTo(offset uint64) error {
+	kv.waitForExports()
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	if kv.readOnly {
+		return ErrReadOnly
+	}
+	if len(kv.reservations) > 0 {
+		return ErrReservationOpen
+	}
+	if err := kv.flush(); err != nil {
+		return err
+	}
+	info, err := kv.f.Stat()
+	if err != nil {
+		return err
+	}
+	if offset > uint64(info.Size()) {
+		return fmt.Errorf("offset %d is past the end of the file", offset)
+	}
+
+	boundary := uint64(fileHeaderSize)
+	for boundary < offset {
+		keySize, dataSize, _, headerSize, err := kv.readPageHeader(int64(boundary))
+		if err != nil {
+			return fmt.Errorf("scanning for record boundary: %w", err)
+		}
+		boundary += headerSize + keySize + dataSize
+	}
+	if boundary != offset {
+		return fmt.Errorf("offset %d is not on a record boundary", offset)
+	}
+
+	if err := kv.f.Truncate(int64(offset)); err != nil {
+		return err
+	}
+
+	kv.pages = make(map[string]Page)
+	kv.buckets = nil
+	kv.cache.clear()
+	kv.lastOffset = uint64(fileHeaderSize)
+	kv.deadBytes = 0
+	if err := kv.loadFromStorage(); err != nil {
+		return err
+	}
+	if err := kv.checkUnreadTail(); err != nil {
+		return err
+	}
+	return kv.refreshPins()
+}
+
+// ConnectAt opens the database file at path for recovery tooling that already
+// knows where the good data ends. Everything after lastOffset is cut off, the
+// index is rebuilt from the records before it and new writes are appended
+// there. lastOffset must fall on a record boundary; the first one is right
+// after the file header.
+func (kv *KV) ConnectAt(path string, lastOffset uint64) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	kv.f = f
+	kv.readOnly = false
+	kv.resetIndex()
+	err = kv.checkFileHeader()
+	if err == nil {
+		err = kv.truncateTo(lastOffset)
+	}
+	if err != nil {
+		f.Close()
+		kv.f = nil
+		return err
+	}
+	return nil
+}
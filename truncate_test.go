@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fileSize returns the size of the database file of kv.
+func fileSize(t testing.TB, kv *KV) int64 {
+	t.Helper()
+	info, err := os.Stat(kv.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestTruncateTo(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	mustInsert(t, kv, "c", "3")
+	end := kv.lastOffset
+	mustInsert(t, kv, "d", "4")
+	mustInsert(t, kv, "a", "5")
+
+	size := fileSize(t, kv)
+	if err := kv.TruncateTo(end + 1); err == nil {
+		t.Fatal("TruncateTo inside a record succeeded")
+	}
+	if err := kv.TruncateTo(uint64(size) + 1); err == nil {
+		t.Fatal("TruncateTo past the end succeeded")
+	}
+	if got := fileSize(t, kv); got != size {
+		t.Fatalf("failed TruncateTo changed the file size from %d to %d", size, got)
+	}
+	wantValue(t, kv, "d", "4")
+
+	if err := kv.TruncateTo(end); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileSize(t, kv); got != int64(end) {
+		t.Fatalf("file size after TruncateTo = %d, want %d", got, end)
+	}
+	if _, err := kv.Get("d"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get d after TruncateTo: %v", err)
+	}
+	wantValue(t, kv, "a", "1")
+	wantValue(t, kv, "c", "3")
+	if kv.Len() != 3 {
+		t.Fatalf("Len after TruncateTo = %d, want 3", kv.Len())
+	}
+
+	// New writes append at the new end.
+	mustInsert(t, kv, "e", "5")
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, kv, "e", "5")
+
+	if err := kv.TruncateTo(uint64(fileHeaderSize)); err != nil {
+		t.Fatal(err)
+	}
+	if kv.Len() != 0 {
+		t.Fatalf("Len after truncating every record = %d", kv.Len())
+	}
+}
+
+func TestConnectAt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	kv := openTestKVAt(t, path, Options{})
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	good := kv.lastOffset
+	kv.Close()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("\x05\x00\x00\x00\x00\x00\x00\x00garbage that is not a record"))
+	f.Close()
+
+	kv = NewKV()
+	if err := kv.ConnectAt(path, good+3); err == nil {
+		t.Fatal("ConnectAt inside the garbage succeeded")
+	}
+	if err := kv.ConnectAt(path, good); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { kv.Close() })
+	wantValue(t, kv, "a", "1")
+	wantValue(t, kv, "b", "2")
+	if kv.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", kv.Len())
+	}
+	mustInsert(t, kv, "c", "3")
+	if err := kv.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	kv.Close()
+
+	kv = openTestKVAt(t, path, Options{})
+	wantValue(t, kv, "c", "3")
+
+	// An earlier boundary rolls back further.
+	kv.Close()
+	kv = NewKV()
+	if err := kv.ConnectAt(path, uint64(fileHeaderSize)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { kv.Close() })
+	if kv.Len() != 0 {
+		t.Fatalf("Len after ConnectAt the first record = %d", kv.Len())
+	}
+}
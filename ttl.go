@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+)
+
+// expiresMeta is the metadata entry holding the expiry time of a key written
+// by InsertWithTTL, as 8 little endian bytes of Unix nanoseconds. Keeping it
+// in the metadata block means files with expiring keys stay readable by
+// versions that know nothing about expiry; those simply never expire them.
+// Its name is reserved, so callers can neither set nor see it.
+const expiresMeta = reservedMetaPrefix + "expires"
+
+// InsertWithTTL stores value under key until ttl has passed. After that the
+// key reads as missing, is left out of scans, key listings, comparisons and
+// compaction, and is dropped from the index the next time the file is
+// loaded. Until then it still counts towards Len. Writing the key again
+// without a TTL keeps it for good.
+func (kv *KV) InsertWithTTL(key string, value []byte, ttl time.Duration) error {
+	return kv.InsertWithMetaTTL(key, value, nil, ttl)
+}
+
+// InsertWithMetaTTL stores value under key with metadata, as InsertWithMeta
+// does, until ttl has passed, as InsertWithTTL does.
+func (kv *KV) InsertWithMetaTTL(key string, value []byte, meta map[string]string, ttl time.Duration) error {
+	if err := checkMetaNames(meta); err != nil {
+		return err
+	}
+	expires := make([]byte, 8)
+	binary.LittleEndian.PutUint64(expires, uint64(time.Now().Add(ttl).UnixNano()))
+	entries := map[string]string{expiresMeta: string(expires)}
+	for name, v := range meta {
+		entries[name] = v
+	}
+	return kv.insertThrottled(context.Background(), key, encodeMeta(entries), value)
+}
+
+// metaExpiry returns the expiry time recorded in a metadata block, including
+// its length prefix, or 0 if it has none.
+func metaExpiry(block []byte) int64 {
+	if len(block) < 8 {
+		return 0
+	}
+	meta, err := decodeMeta(block[8:])
+	if err != nil || len(meta[expiresMeta]) != 8 {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint64([]byte(meta[expiresMeta])))
+}
+
+// expired reports whether the TTL of the record described by page has
+// passed.
+func (p Page) expired() bool {
+	return p.expires != 0 && time.Now().UnixNano() >= p.expires
+}
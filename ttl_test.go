@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// insertExpired stores key with a TTL and waits for it to pass.
+func insertExpired(t *testing.T, kv *KV, key, value string) {
+	t.Helper()
+	if err := kv.InsertWithTTL(key, []byte(value), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+}
+
+func TestInsertWithTTL(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	kv := openTestKVAt(t, path, Options{})
+	if err := kv.InsertWithTTL("long", []byte("v"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	insertExpired(t, kv, "short", "v")
+
+	wantValue(t, kv, "long", "v")
+	if _, err := kv.Get("short"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get expired key: %v", err)
+	}
+	if keys := kv.sortedKeys(); len(keys) != 1 || keys[0] != "long" {
+		t.Fatalf("keys = %q", keys)
+	}
+	if _, meta, err := kv.GetWithMeta("long"); err != nil || len(meta) != 0 {
+		t.Fatalf("GetWithMeta = %v, %v", meta, err)
+	}
+
+	// The expired key is dropped on load, whether from the sidecar index or
+	// a scan of the file.
+	if err := kv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	kv = openTestKVAt(t, path, Options{})
+	if kv.Exists("short") || !kv.Exists("long") {
+		t.Fatalf("after reload: short %v, long %v", kv.Exists("short"), kv.Exists("long"))
+	}
+	if err := kv.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if kv.Exists("short") || !kv.Exists("long") {
+		t.Fatalf("after rescan: short %v, long %v", kv.Exists("short"), kv.Exists("long"))
+	}
+}
+
+func TestInsertWithoutTTLKeepsKey(t *testing.T) {
+	kv := openTestKV(t)
+	if err := kv.InsertWithTTL("k", []byte("1"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	mustInsert(t, kv, "k", "2")
+	time.Sleep(5 * time.Millisecond)
+	wantValue(t, kv, "k", "2")
+}
+
+func TestExpiredKeysSkippedByWalkers(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	insertExpired(t, kv, "b", "2")
+
+	var seen []string
+	err := kv.Filter(func(string, []byte) bool { return true }, func(key string, _ []byte) error {
+		seen = append(seen, key)
+		return nil
+	})
+	if err != nil || len(seen) != 1 || seen[0] != "a" {
+		t.Fatalf("Filter saw %q, %v", seen, err)
+	}
+
+	root, err := kv.MerkleRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.MerkleProof("b"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("MerkleProof of expired key: %v", err)
+	}
+	proof, err := kv.MerkleProof("a")
+	if err != nil || !proof.Verify(root) {
+		t.Fatalf("MerkleProof(a) = %v, %v", proof, err)
+	}
+
+	other := openTestKV(t)
+	mustInsert(t, other, "a", "1")
+	otherRoot, err := other.MerkleRoot()
+	if err != nil || !bytes.Equal(root, otherRoot) {
+		t.Fatalf("roots differ with only an expired key apart: %v", err)
+	}
+
+	mustInsert(t, other, "b", "2")
+	added, modified, removed, err := Diff(other, kv)
+	if err != nil || len(added) != 0 || len(modified) != 0 || len(removed) != 1 || removed[0] != "b" {
+		t.Fatalf("Diff = %q %q %q, %v", added, modified, removed, err)
+	}
+	added, modified, removed, err = Diff(kv, other)
+	if err != nil || len(added) != 1 || added[0] != "b" || len(modified) != 0 || len(removed) != 0 {
+		t.Fatalf("reverse Diff = %q %q %q, %v", added, modified, removed, err)
+	}
+	if keys := kv.Snapshot().Keys(); len(keys) != 1 || keys[0] != "a" {
+		t.Fatalf("snapshot keys = %q", keys)
+	}
+}
+
+func TestTTLMetaIsReserved(t *testing.T) {
+	kv := openTestKV(t)
+	err := kv.InsertWithMeta("a", []byte("1"), map[string]string{expiresMeta: "\x00\x00\x00\x00\x00\x00\x00\x01"})
+	if !errors.Is(err, ErrReservedMeta) {
+		t.Fatalf("InsertWithMeta with a reserved name: %v", err)
+	}
+	if kv.Exists("a") {
+		t.Fatal("rejected insert was stored")
+	}
+
+	meta := map[string]string{"type": "text"}
+	if err := kv.InsertWithMetaTTL("b", []byte("2"), meta, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	value, got, err := kv.GetWithMeta("b")
+	if err != nil || string(value) != "2" || len(got) != 1 || got["type"] != "text" {
+		t.Fatalf("GetWithMeta = %q, %v, %v", value, got, err)
+	}
+	if kv.pages["b"].expires == 0 {
+		t.Fatal("InsertWithMetaTTL did not set an expiry")
+	}
+
+	insertExpired(t, kv, "c", "3")
+	if _, _, err := kv.GetWithMeta("c"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetWithMeta of an expired key: %v", err)
+	}
+	if err := kv.InsertWithMetaTTL("d", nil, map[string]string{"voila.x": ""}, time.Hour); !errors.Is(err, ErrReservedMeta) {
+		t.Fatalf("InsertWithMetaTTL with a reserved name: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// varintFormatVersion marks a file whose record headers are varint encoded.
+// Such a header is the key size as a uvarint followed by the data size
+// shifted left by 8 and the 8 flag bits below it, also as a uvarint. A record
+// with a short key and a value under a few kilobytes then has a 3 or 4 byte
+// header instead of 16. Everything after the header is laid out as usual.
+const varintFormatVersion = 2
+
+// WithVarintHeaders makes a new database file use varint record headers,
+// which saves most of the 16 bytes of overhead per record when keys and
+// values are small. It has no effect on an existing file: the format in its
+// file header always decides how its records are read and written.
+func WithVarintHeaders() Option {
+	return func(kv *KV) {
+		kv.varintHeaders = true
+	}
+}
+
+// storedRecord returns record, as laid out by encodePage, with its header in
+// the format of the open file. Only the header is converted, so record may be
+// the start of a record.
+func (kv *KV) storedRecord(record []byte) []byte {
+	if !kv.varintHeaders {
+		return record
+	}
+	keySize := binary.LittleEndian.Uint64(record[:8])
+	valueSize := binary.LittleEndian.Uint64(record[8:16])
+	return append(varintHeader(keySize, valueSize), record[16:]...)
+}
+
+// recordHeader encodes a record header in the format of the open file.
+// valueSize carries the flags in its high bits, as in the fixed layout.
+func (kv *KV) recordHeader(keySize, valueSize uint64) []byte {
+	if kv.varintHeaders {
+		return varintHeader(keySize, valueSize)
+	}
+	return fixedHeader(keySize, valueSize)
+}
+
+func fixedHeader(keySize, valueSize uint64) []byte {
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint64(header[:8], keySize)
+	binary.LittleEndian.PutUint64(header[8:], valueSize)
+	return header
+}
+
+func varintHeader(keySize, valueSize uint64) []byte {
+	packed := (valueSize&sizeMask)<<8 | valueSize>>56
+	header := binary.AppendUvarint(nil, keySize)
+	return binary.AppendUvarint(header, packed)
+}
+
+func (kv *KV) readVarintHeader(offset int64) (keySize, dataSize, flags, headerSize uint64, err error) {
+	buf := make([]byte, 2*binary.MaxVarintLen64)
+	n, err := kv.f.ReadAt(buf, offset)
+	if n == 0 {
+		return 0, 0, 0, 0, err
+	}
+	return decodeVarintHeader(buf[:n])
+}
+
+// decodeVarintHeader decodes the varint header at the start of buf, which
+// may run on past the header.
+func decodeVarintHeader(buf []byte) (keySize, dataSize, flags, headerSize uint64, err error) {
+	keySize, k := binary.Uvarint(buf)
+	var packed uint64
+	var p int
+	if k > 0 {
+		packed, p = binary.Uvarint(buf[k:])
+	}
+	if k <= 0 || p <= 0 {
+		if k == 0 || p == 0 {
+			// Ran out of bytes mid-header.
+			return 0, 0, 0, 0, io.ErrUnexpectedEOF
+		}
+		return 0, 0, 0, 0, errors.New("malformed record header")
+	}
+	return keySize, packed >> 8, packed << 56, uint64(k + p), nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestVarintHeaders(t *testing.T) {
+	path := t.TempDir() + "/db"
+	fixed := openTestKV(t)
+	varint := openTestKVAt(t, path, Options{}, WithVarintHeaders())
+	for _, kv := range []*KV{fixed, varint} {
+		mustInsert(t, kv, "a", "1")
+		mustInsert(t, kv, "b", string(make([]byte, 1000)))
+		if err := kv.InsertWithMeta("c", []byte("3"), map[string]string{"type": "text"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := kv.InsertWithTTL("d", []byte("4"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		mustInsert(t, kv, "e", "5")
+		if err := kv.Delete("e"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantSameContents(t, fixed, varint)
+	if fileSize(t, varint) >= fileSize(t, fixed) {
+		t.Fatalf("varint file is %d bytes, fixed is %d", fileSize(t, varint), fileSize(t, fixed))
+	}
+	varint.Close()
+	os.Remove(indexPath(path))
+
+	// The file header decides the format, whatever the options say.
+	reopened := openTestKVAt(t, path, Options{})
+	wantSameContents(t, fixed, reopened)
+	mustInsert(t, reopened, "f", "6")
+	reopened.Close()
+	os.Remove(indexPath(path))
+	reopened = openTestKVAt(t, path, Options{})
+	wantValue(t, reopened, "f", "6")
+}
+
+func BenchmarkFileSize(b *testing.B) {
+	for name, opts := range map[string][]Option{"fixed": nil, "varint": {WithVarintHeaders()}} {
+		b.Run(name, func(b *testing.B) {
+			var size int64
+			for i := 0; i < b.N; i++ {
+				kv := openTestKV(b, opts...)
+				for j := 0; j < 10000; j++ {
+					if err := kv.Insert(fmt.Sprintf("k%d", j), []byte("v")); err != nil {
+						b.Fatal(err)
+					}
+				}
+				size = fileSize(b, kv)
+				kv.Close()
+			}
+			b.ReportMetric(float64(size), "file-bytes")
+		})
+	}
+}
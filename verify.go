@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrSizeMismatch = errors.New("file size does not match the records it contains")
+
+// WithStrictChecks makes Close run Verify before closing the file.
+func WithStrictChecks() Option {
+	return func(kv *KV) {
+		kv.strictChecks = true
+	}
+}
+
+// Verify walks the record headers from the start of the file and checks that
+// they account for every byte of it. A mismatch means either a bug in offset
+// accounting or bytes written to the file by something other than voila.
+func (kv *KV) Verify() error {
+	unlock := kv.readLock()
+	defer unlock()
+	return kv.verify()
+}
+
+func (kv *KV) verify() error {
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+	info, err := kv.f.Stat()
+	if err != nil {
+		return err
+	}
+	size := uint64(info.Size())
+
+	offset := uint64(fileHeaderSize)
+	for offset < size {
+		keySize, dataSize, _, headerSize, err := kv.readPageHeader(int64(offset))
+		if err != nil {
+			return fmt.Errorf("%w: unreadable record header at offset %d", ErrSizeMismatch, offset)
+		}
+		offset += headerSize + keySize + dataSize
+	}
+	if offset != size {
+		return fmt.Errorf("%w: records end at %d but file is %d bytes", ErrSizeMismatch, offset, size)
+	}
+	return nil
+}
+
+// ValidateIndex checks every entry of the in-memory index against the record
+// it points at on disk: the record must exist, hold the same key and have the
+// sizes recorded in the Page. All mismatches are reported, each naming its
+// key and offset.
+func (kv *KV) ValidateIndex() error {
+	unlock := kv.readLock()
+	defer unlock()
+
+	if kv.f == nil {
+		return ErrDBNotOpen
+	}
+
+	var errs []error
+	for _, key := range kv.sortedKeys() {
+		page := kv.pages[key]
+		if err := kv.validatePage(key, page); err != nil {
+			errs = append(errs, fmt.Errorf("key %q at offset %d: %w", key, page.offset, err))
+		}
+	}
+	kv.eachBucketPage(func(bucket, key string, page Page) error {
+		if err := kv.validatePage(key, page); err != nil {
+			errs = append(errs, fmt.Errorf("bucket %q key %q at offset %d: %w", bucket, key, page.offset, err))
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+func (kv *KV) validatePage(key string, page Page) error {
+	keySize, dataSize, flags, headerSize, err := kv.readPageHeader(int64(page.offset))
+	if err != nil {
+		return fmt.Errorf("unreadable record header: %w", err)
+	}
+	if flags&reservedFlag != 0 {
+		return errors.New("record is an unfinished reservation")
+	}
+	if flags&tombstoneFlag != 0 {
+		return errors.New("record is a tombstone")
+	}
+	if (flags&metaFlag != 0) != (page.metaSize > 0) {
+		return errors.New("metadata flag does not match index")
+	}
+	if uint8(flags>>transformShift&0xf) != page.transforms {
+		return errors.New("transformer bits do not match index")
+	}
+	if (flags&checksumFlag != 0) != page.checksummed {
+		return errors.New("checksum flag does not match index")
+	}
+	indexed := page.metaSize + page.valueSize
+	if page.checksummed {
+		indexed += crcSize
+	}
+	if keySize != page.keySize || dataSize != indexed || headerSize+keySize+dataSize != page.size {
+		return fmt.Errorf("record sizes (key %d, data %d) do not match index (key %d, data %d)",
+			keySize, dataSize, page.keySize, indexed)
+	}
+
+	keyBuf := make([]byte, keySize)
+	if _, err := kv.f.ReadAt(keyBuf, int64(page.offset+headerSize)); err != nil {
+		return fmt.Errorf("unreadable key: %w", err)
+	}
+	if string(keyBuf) != key {
+		return fmt.Errorf("record holds key %q", keyBuf)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// appendSlack appends bytes no record accounts for to the file of kv.
+func appendSlack(t *testing.T, kv *KV, slack []byte) {
+	t.Helper()
+	f, err := os.OpenFile(kv.Path(), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(slack); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for name, opts := range map[string][]Option{"fixed": nil, "varint": {WithVarintHeaders()}} {
+		t.Run(name, func(t *testing.T) {
+			kv := openTestKV(t, opts...)
+			if err := kv.Verify(); err != nil {
+				t.Fatalf("Verify empty database: %v", err)
+			}
+			mustInsert(t, kv, "a", "1")
+			mustInsert(t, kv, "a", "2")
+			mustInsert(t, kv, "b", "3")
+			if err := kv.Delete("b"); err != nil {
+				t.Fatal(err)
+			}
+			if err := kv.InsertWithMeta("m", []byte("v"), map[string]string{"k": "v"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := kv.Verify(); err != nil {
+				t.Fatalf("Verify clean database: %v", err)
+			}
+
+			appendSlack(t, kv, []byte("slack"))
+			if err := kv.Verify(); !errors.Is(err, ErrSizeMismatch) {
+				t.Fatalf("Verify with slack bytes: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRecordPastEnd(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	if err := kv.f.Truncate(int64(kv.lastOffset - 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Verify(); !errors.Is(err, ErrSizeMismatch) {
+		t.Fatalf("Verify with a cut short record: %v", err)
+	}
+}
+
+func TestStrictChecksOnClose(t *testing.T) {
+	kv := openTestKV(t, WithStrictChecks())
+	mustInsert(t, kv, "a", "1")
+	appendSlack(t, kv, make([]byte, 3))
+	if err := kv.Close(); !errors.Is(err, ErrSizeMismatch) {
+		t.Fatalf("Close with slack bytes: %v", err)
+	}
+
+	clean := openTestKV(t, WithStrictChecks())
+	mustInsert(t, clean, "a", "1")
+	if err := clean.Close(); err != nil {
+		t.Fatalf("Close clean database: %v", err)
+	}
+}
+
+func TestValidateIndex(t *testing.T) {
+	kv := openTestKV(t)
+	mustInsert(t, kv, "a", "1")
+	mustInsert(t, kv, "b", "2")
+	if err := kv.InsertWithMeta("m", []byte("v"), map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.ValidateIndex(); err != nil {
+		t.Fatalf("ValidateIndex clean index: %v", err)
+	}
+
+	// Point a at b's record of the same size, and give m the wrong value
+	// size.
+	a := kv.pages["a"]
+	a.offset = kv.pages["b"].offset
+	kv.pages["a"] = a
+	m := kv.pages["m"]
+	m.valueSize++
+	kv.pages["m"] = m
+
+	err := kv.ValidateIndex()
+	if err == nil {
+		t.Fatal("ValidateIndex missed a corrupt index")
+	}
+	for _, want := range []string{
+		fmt.Sprintf(`key "a" at offset %d: record holds key "b"`, a.offset),
+		fmt.Sprintf(`key "m" at offset %d: record sizes`, m.offset),
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateIndex error %q does not contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), `key "b"`+" at") {
+		t.Errorf("ValidateIndex flagged b: %v", err)
+	}
+}